@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"os"
+
+	"github.com/sherif-fanous/m3u"
+)
+
+func runApply(args []string) error {
+	fs := newFlagSet("apply")
+	rulesPath := fs.String("rules", "", "JSON or YAML rules file to apply (required)")
+	typ := fs.String("type", "m3uplus", "output playlist type: m3u or m3uplus")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *rulesPath == "" {
+		return errors.New("the -rules flag is required")
+	}
+
+	playlistType, err := parsePlaylistType(*typ)
+	if err != nil {
+		return err
+	}
+
+	rules, err := m3u.LoadRulesFile(*rulesPath)
+	if err != nil {
+		return err
+	}
+
+	playlist, err := readPlaylist(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	if err := rules.Apply(playlist); err != nil {
+		return err
+	}
+
+	return m3u.NewEncoder(os.Stdout).Encode(playlist, playlistType)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sherif-fanous/m3u"
+)
+
+func runLint(args []string) error {
+	fs := newFlagSet("lint")
+	enable := fs.String("enable", "", "comma-separated rule IDs to enable")
+	disable := fs.String("disable", "", "comma-separated rule IDs to disable")
+	severities := fs.String("severity", "", "comma-separated rule=severity overrides (info, warning or error)")
+	list := fs.Bool("list", false, "list the available rules and exit")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *list {
+		for _, rule := range m3u.LintRules() {
+			state := "enabled"
+			if !rule.Enabled {
+				state = "disabled"
+			}
+
+			fmt.Printf("%-22s %-8s %-9s %s\n", rule.ID, rule.Severity, state, rule.Description)
+		}
+
+		return nil
+	}
+
+	linter := &m3u.Linter{
+		Enabled:  make(map[string]bool),
+		Severity: make(map[string]m3u.Severity),
+	}
+
+	for _, id := range splitList(*enable) {
+		if err := checkLintRule(id); err != nil {
+			return err
+		}
+
+		linter.Enabled[id] = true
+	}
+
+	for _, id := range splitList(*disable) {
+		if err := checkLintRule(id); err != nil {
+			return err
+		}
+
+		linter.Enabled[id] = false
+	}
+
+	for _, override := range splitList(*severities) {
+		id, name, ok := strings.Cut(override, "=")
+		if !ok {
+			return fmt.Errorf("invalid severity override %q: must be rule=severity", override)
+		}
+
+		if err := checkLintRule(id); err != nil {
+			return err
+		}
+
+		severity, err := m3u.ParseSeverity(name)
+		if err != nil {
+			return err
+		}
+
+		linter.Severity[id] = severity
+	}
+
+	var (
+		r    io.Reader = os.Stdin
+		name           = "<stdin>"
+	)
+
+	if fs.NArg() > 0 {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		r, name = f, fs.Arg(0)
+	}
+
+	findings, err := linter.Lint(r)
+
+	failed := 0
+	for _, finding := range findings {
+		fmt.Printf("%s:%d: %s: %s [%s]\n",
+			name, finding.LineNumber, finding.Severity, finding.Message, finding.Rule)
+
+		if finding.Severity >= m3u.SeverityWarning {
+			failed++
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d problems found", failed)
+	}
+
+	return nil
+}
+
+// checkLintRule returns an error if id is not the ID of a lint rule, so that
+// misspelled rules are not silently ignored.
+func checkLintRule(id string) error {
+	for _, rule := range m3u.LintRules() {
+		if rule.ID == id {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown lint rule %q (see -list)", id)
+}
+
+// splitList splits a comma-separated flag value, ignoring empty elements.
+func splitList(s string) []string {
+	var list []string
+
+	for _, elem := range strings.Split(s, ",") {
+		if elem = strings.TrimSpace(elem); elem != "" {
+			list = append(list, elem)
+		}
+	}
+
+	return list
+}
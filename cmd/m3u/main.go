@@ -0,0 +1,123 @@
+// Command m3u inspects and transforms M3U playlists.
+//
+// Usage:
+//
+//	m3u <command> [flags] [playlist]
+//
+// The commands are:
+//
+//	apply    transform tracks with a rules file
+//	lint     report problems that players are likely to choke on
+//	rebase   rewrite file paths when moving a playlist or music library
+//	redact   mask credentials in URLs before sharing a playlist
+//	split    write one playlist file per group
+//
+// When no playlist file is given, the playlist is read from standard input.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sherif-fanous/m3u"
+)
+
+// command is a subcommand of the m3u tool.
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []command{
+	{name: "apply", usage: "transform tracks with a rules file", run: runApply},
+	{name: "lint", usage: "report problems that players are likely to choke on", run: runLint},
+	{name: "rebase", usage: "rewrite file paths when moving a playlist or music library", run: runRebase},
+	{name: "redact", usage: "mask credentials in URLs before sharing a playlist", run: runRedact},
+	{name: "split", usage: "write one playlist file per group", run: runSplit},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == os.Args[1] {
+			if err := cmd.run(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "m3u %s: %v\n", cmd.name, err)
+				os.Exit(1)
+			}
+
+			return
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "m3u: unknown command %q\n", os.Args[1])
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: m3u <command> [flags] [playlist]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", cmd.name, cmd.usage)
+	}
+}
+
+// newFlagSet returns a flag set for the named command.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("m3u "+name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: m3u %s [flags] [playlist]\n", name)
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+// readPlaylist decodes the playlist named by the first of args, or standard
+// input if args is empty. Decoder warnings are printed to standard error.
+func readPlaylist(args []string, opts ...m3u.DecoderOption) (*m3u.Playlist, error) {
+	var r io.Reader = os.Stdin
+
+	if len(args) > 0 {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		r = f
+	}
+
+	playlist := &m3u.Playlist{}
+
+	decoder := m3u.NewDecoder(r, opts...)
+	if err := decoder.Decode(playlist); err != nil {
+		return nil, err
+	}
+
+	for _, warning := range decoder.Warnings() {
+		fmt.Fprintln(os.Stderr, warning)
+	}
+
+	return playlist, nil
+}
+
+// parsePlaylistType parses the value of a -type flag.
+func parsePlaylistType(s string) (m3u.PlaylistType, error) {
+	switch s {
+	case "m3u":
+		return m3u.M3U, nil
+	case "m3uplus":
+		return m3u.M3UPlus, nil
+	default:
+		return "", fmt.Errorf("unknown playlist type %q: must be m3u or m3uplus", s)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sherif-fanous/m3u"
+)
+
+func runRebase(args []string) error {
+	fs := newFlagSet("rebase")
+	from := fs.String("from", "", "directory relative paths are relative to (default: directory of the playlist)")
+	to := fs.String("to", "", "directory to write paths relative to (default: -from)")
+	absolute := fs.Bool("absolute", false, "write absolute paths")
+	backslashes := fs.Bool("backslashes", false, "separate directories with backslashes")
+	typ := fs.String("type", "m3uplus", "output playlist type: m3u or m3uplus")
+
+	var replacements []m3u.PathReplacement
+
+	fs.Func("replace", "replace the directory `old=new` in paths (repeatable)", func(s string) error {
+		old, replacement, ok := strings.Cut(s, "=")
+		if !ok || old == "" {
+			return errors.New("must be old=new")
+		}
+
+		replacements = append(replacements, m3u.PathReplacement{Old: old, New: replacement})
+
+		return nil
+	})
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	playlistType, err := parsePlaylistType(*typ)
+	if err != nil {
+		return err
+	}
+
+	playlist, err := readPlaylist(fs.Args(), m3u.WithFilePaths())
+	if err != nil {
+		return err
+	}
+
+	opts := m3u.RebaseOptions{
+		From:         *from,
+		To:           *to,
+		Replacements: replacements,
+		Absolute:     *absolute,
+		CheckExists:  true,
+	}
+
+	if opts.From == "" && len(fs.Args()) > 0 {
+		opts.From = filepath.Dir(fs.Args()[0])
+	}
+
+	if *backslashes {
+		opts.Separator = '\\'
+	}
+
+	report := playlist.Rebase(opts)
+
+	if err := m3u.NewEncoder(os.Stdout, m3u.WithFilePathOutput()).Encode(playlist, playlistType); err != nil {
+		return err
+	}
+
+	for _, i := range report.Missing {
+		track := playlist.Tracks[i]
+		fmt.Fprintf(os.Stderr, "missing: %s: %s\n", track.Name, track.URL.Path)
+	}
+
+	fmt.Fprintf(os.Stderr, "rebased %d tracks, %d missing\n", report.Rebased, len(report.Missing))
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/sherif-fanous/m3u"
+)
+
+func runRedact(args []string) error {
+	fs := newFlagSet("redact")
+	typ := fs.String("type", "m3uplus", "output playlist type: m3u or m3uplus")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	playlistType, err := parsePlaylistType(*typ)
+	if err != nil {
+		return err
+	}
+
+	playlist, err := readPlaylist(fs.Args(), m3u.WithRedactedErrors())
+	if err != nil {
+		return err
+	}
+
+	count := playlist.Redact()
+
+	if err := m3u.NewEncoder(os.Stdout).Encode(playlist, playlistType); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "redacted %d values\n", count)
+
+	return nil
+}
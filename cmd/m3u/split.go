@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/sherif-fanous/m3u"
+)
+
+func runSplit(args []string) error {
+	fs := newFlagSet("split")
+	dir := fs.String("dir", ".", "directory to write the group playlists to")
+	typ := fs.String("type", "m3uplus", "output playlist type: m3u or m3uplus")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	playlistType, err := parsePlaylistType(*typ)
+	if err != nil {
+		return err
+	}
+
+	playlist, err := readPlaylist(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(*dir, 0o755); err != nil {
+		return err
+	}
+
+	playlists := playlist.SplitByGroup()
+	used := make(map[string]bool)
+
+	for _, group := range playlist.Groups() {
+		name := uniqueFilename(sanitizeFilename(group.Title), used)
+
+		data, err := m3u.Marshal(playlists[group.Title], playlistType)
+		if err != nil {
+			return err
+		}
+
+		path := filepath.Join(*dir, name+".m3u")
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return err
+		}
+
+		fmt.Printf("%s\t%d\n", path, group.Count)
+	}
+
+	return nil
+}
+
+// sanitizeFilename turns a group title into a name that is safe to use as a
+// file name on common file systems.
+func sanitizeFilename(title string) string {
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+
+		return r
+	}, title)
+
+	// Windows rejects trailing dots and spaces, and leading dots hide files
+	name = strings.Trim(name, ". ")
+	if name == "" {
+		return "ungrouped"
+	}
+
+	return name
+}
+
+// uniqueFilename returns name, suffixed with a counter if it has already been
+// used. Names are compared case-insensitively, as on case-insensitive file
+// systems.
+func uniqueFilename(name string, used map[string]bool) string {
+	unique := name
+	for i := 2; used[strings.ToLower(unique)]; i++ {
+		unique = fmt.Sprintf("%s (%d)", name, i)
+	}
+
+	used[strings.ToLower(unique)] = true
+
+	return unique
+}
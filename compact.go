@@ -0,0 +1,351 @@
+package m3u
+
+import (
+	"iter"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// CompactPlaylist is a memory-efficient representation of a playlist with
+// many tracks. Its strings are stored once in a table shared by every track,
+// so memory grows with the number of unique values rather than with the
+// number of tracks, and Track values are only materialized when read.
+//
+// Tracks are read with Track and All, and added with Append. The materialized
+// tracks are copies; changing them does not change the compact playlist.
+type CompactPlaylist struct {
+	// Header holds the attributes of the playlist. Its Tracks field is not
+	// used.
+	Header Playlist
+
+	// table is the string table, and index maps its strings to their
+	// references
+	table []string
+	index map[string]uint32
+
+	tracks     []compactTrack
+	attributes []compactAttribute
+	// refs holds the directives, attribute orders and alternate URLs of the
+	// tracks
+	refs []uint32
+}
+
+// compactTrack is a track whose strings are references to the string table of
+// its playlist. Reference 0 marks an unset field.
+type compactTrack struct {
+	length float64
+
+	name        uint32
+	tvgID       uint32
+	tvgName     uint32
+	tvgLanguage uint32
+	tvgLogo     uint32
+	groupTitle  uint32
+	url         uint32
+	album       uint32
+	artist      uint32
+	genre       uint32
+	image       uint32
+
+	// The extra attributes of the track are the range
+	// [attributesStart, attributesEnd) of the attributes of its playlist, and
+	// its extra directives, attribute order and alternate URLs the ranges
+	// [directivesStart, directivesEnd), [directivesEnd, orderEnd) and
+	// [orderEnd, alternatesEnd) of its refs
+	attributesStart uint32
+	attributesEnd   uint32
+	directivesStart uint32
+	directivesEnd   uint32
+	orderEnd        uint32
+	alternatesEnd   uint32
+}
+
+// compactAttribute is an extra attribute of a compactTrack. It also records
+// the quoting of the built-in attributes that are not double-quoted, with a
+// value reference of 0.
+type compactAttribute struct {
+	key     uint32
+	value   uint32
+	quoting Quoting
+}
+
+// NewCompactPlaylist returns a compact copy of p.
+func NewCompactPlaylist(p *Playlist) *CompactPlaylist {
+	c := &CompactPlaylist{}
+	c.Reset()
+
+	c.Header = Playlist{
+		TVGURL:           p.TVGURL,
+		XTVGURL:          p.XTVGURL,
+		Title:            p.Title,
+		Album:            p.Album,
+		Artist:           p.Artist,
+		Genre:            p.Genre,
+		Image:            p.Image,
+		ExtraAttributes:  maps.Clone(p.ExtraAttributes),
+		AttributeQuoting: maps.Clone(p.AttributeQuoting),
+		AttributeOrder:   slices.Clone(p.AttributeOrder),
+	}
+
+	for _, track := range p.Tracks {
+		c.Append(track)
+	}
+
+	return c
+}
+
+// Reset removes the header and tracks of the playlist.
+func (c *CompactPlaylist) Reset() {
+	*c = CompactPlaylist{
+		// Reference 0 is reserved for unset fields
+		table: []string{""},
+		index: make(map[string]uint32),
+	}
+}
+
+// Len returns the number of tracks.
+func (c *CompactPlaylist) Len() int {
+	return len(c.tracks)
+}
+
+// Append adds a copy of track to the end of the playlist.
+func (c *CompactPlaylist) Append(track Track) {
+	if c.index == nil {
+		c.Reset()
+	}
+
+	ct := compactTrack{
+		length:          track.Length,
+		name:            c.ref(track.Name),
+		tvgID:           c.optionalRef(track.TVGID),
+		tvgName:         c.optionalRef(track.TVGName),
+		tvgLanguage:     c.optionalRef(track.TVGLanguage),
+		tvgLogo:         c.urlRef(track.TVGLogo),
+		groupTitle:      c.optionalRef(track.GroupTitle),
+		url:             c.locationRef(track.URL),
+		album:           c.optionalRef(track.Album),
+		artist:          c.optionalRef(track.Artist),
+		genre:           c.optionalRef(track.Genre),
+		image:           c.locationRef(track.Image),
+		attributesStart: uint32(len(c.attributes)),
+		directivesStart: uint32(len(c.refs)),
+	}
+
+	// Sort the keys, so that equal tracks are stored alike
+	for _, key := range slices.Sorted(maps.Keys(track.ExtraAttributes)) {
+		c.attributes = append(c.attributes, compactAttribute{
+			key:     c.ref(key),
+			value:   c.ref(track.ExtraAttributes[key]),
+			quoting: track.AttributeQuoting[key],
+		})
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(track.AttributeQuoting)) {
+		if _, ok := track.ExtraAttributes[key]; !ok {
+			c.attributes = append(c.attributes, compactAttribute{
+				key:     c.ref(key),
+				quoting: track.AttributeQuoting[key],
+			})
+		}
+	}
+
+	for _, directive := range track.ExtraDirectives {
+		c.refs = append(c.refs, c.ref(directive))
+	}
+
+	ct.directivesEnd = uint32(len(c.refs))
+
+	for _, key := range track.AttributeOrder {
+		c.refs = append(c.refs, c.ref(key))
+	}
+
+	ct.orderEnd = uint32(len(c.refs))
+
+	for _, u := range track.AlternateURLs {
+		c.refs = append(c.refs, c.locationRef(u))
+	}
+
+	ct.attributesEnd = uint32(len(c.attributes))
+	ct.alternatesEnd = uint32(len(c.refs))
+
+	c.tracks = append(c.tracks, ct)
+}
+
+// Track materializes the track at index i. It panics if i is out of range.
+func (c *CompactPlaylist) Track(i int) Track {
+	ct := &c.tracks[i]
+
+	track := Track{
+		Length:      ct.length,
+		Name:        c.table[ct.name],
+		TVGID:       c.optionalString(ct.tvgID),
+		TVGName:     c.optionalString(ct.tvgName),
+		TVGLanguage: c.optionalString(ct.tvgLanguage),
+		TVGLogo:     c.url(ct.tvgLogo),
+		GroupTitle:  c.optionalString(ct.groupTitle),
+		URL:         c.location(ct.url),
+		Album:       c.optionalString(ct.album),
+		Artist:      c.optionalString(ct.artist),
+		Genre:       c.optionalString(ct.genre),
+		Image:       c.location(ct.image),
+	}
+
+	for _, attr := range c.attributes[ct.attributesStart:ct.attributesEnd] {
+		key := c.table[attr.key]
+
+		if attr.value != 0 {
+			if track.ExtraAttributes == nil {
+				track.ExtraAttributes = make(map[string]string)
+			}
+			track.ExtraAttributes[key] = c.table[attr.value]
+		}
+
+		if attr.quoting != DoubleQuoted {
+			if track.AttributeQuoting == nil {
+				track.AttributeQuoting = make(map[string]Quoting)
+			}
+			track.AttributeQuoting[key] = attr.quoting
+		}
+	}
+
+	track.ExtraDirectives = c.stringList(ct.directivesStart, ct.directivesEnd)
+	track.AttributeOrder = c.stringList(ct.directivesEnd, ct.orderEnd)
+
+	if ct.orderEnd != ct.alternatesEnd {
+		track.AlternateURLs = make([]*url.URL, 0, ct.alternatesEnd-ct.orderEnd)
+		for _, r := range c.refs[ct.orderEnd:ct.alternatesEnd] {
+			track.AlternateURLs = append(track.AlternateURLs, c.location(r))
+		}
+	}
+
+	return track
+}
+
+// All returns an iterator over the indexes and materialized tracks of the
+// playlist.
+func (c *CompactPlaylist) All() iter.Seq2[int, Track] {
+	return func(yield func(int, Track) bool) {
+		for i := range c.tracks {
+			if !yield(i, c.Track(i)) {
+				return
+			}
+		}
+	}
+}
+
+// Playlist materializes the playlist with all of its tracks.
+func (c *CompactPlaylist) Playlist() *Playlist {
+	p := c.Header
+	if len(c.tracks) > 0 {
+		p.Tracks = make([]Track, 0, len(c.tracks))
+	}
+
+	for _, track := range c.All() {
+		p.Tracks = append(p.Tracks, track)
+	}
+
+	return &p
+}
+
+// ref returns the reference of s, adding a copy of it to the string table if
+// needed, so that the string s is a part of can be freed. Empty strings are
+// stored like any other, so that they are told apart from unset fields.
+func (c *CompactPlaylist) ref(s string) uint32 {
+	if r, ok := c.index[s]; ok {
+		return r
+	}
+
+	s = strings.Clone(s)
+	r := uint32(len(c.table))
+	c.table = append(c.table, s)
+	c.index[s] = r
+
+	return r
+}
+
+func (c *CompactPlaylist) optionalRef(s *string) uint32 {
+	if s == nil {
+		return 0
+	}
+
+	return c.ref(*s)
+}
+
+func (c *CompactPlaylist) urlRef(u *url.URL) uint32 {
+	if u == nil {
+		return 0
+	}
+
+	return c.ref(u.String())
+}
+
+// stringList returns the strings referenced by refs[start:end], or nil if there
+// are none.
+func (c *CompactPlaylist) stringList(start, end uint32) []string {
+	if start == end {
+		return nil
+	}
+
+	s := make([]string, 0, end-start)
+	for _, r := range c.refs[start:end] {
+		s = append(s, c.table[r])
+	}
+
+	return s
+}
+
+// pathMarker prefixes the stored locations that only hold a path, such as the
+// file paths decoded with WithFilePaths, whose String form does not always
+// parse back to them. It cannot start the String form of a URL, which escapes
+// control characters.
+const pathMarker = "\x00"
+
+func (c *CompactPlaylist) locationRef(u *url.URL) uint32 {
+	if u == nil {
+		return 0
+	}
+
+	if *u == (url.URL{Path: u.Path}) {
+		return c.ref(pathMarker + u.Path)
+	}
+
+	return c.ref(u.String())
+}
+
+func (c *CompactPlaylist) optionalString(r uint32) *string {
+	if r == 0 {
+		return nil
+	}
+
+	s := c.table[r]
+
+	return &s
+}
+
+// url parses the URL with reference r. URLs are stored in their String form,
+// which parses back to the URL they were stored from.
+func (c *CompactPlaylist) url(r uint32) *url.URL {
+	if r == 0 {
+		return nil
+	}
+
+	u, _ := parseURL(c.table[r])
+
+	return u
+}
+
+// location parses the track location with reference r. Locations are stored
+// like URLs, or as their path after pathMarker.
+func (c *CompactPlaylist) location(r uint32) *url.URL {
+	if r == 0 {
+		return nil
+	}
+
+	if p, ok := strings.CutPrefix(c.table[r], pathMarker); ok {
+		return &url.URL{Path: p}
+	}
+
+	return c.url(r)
+}
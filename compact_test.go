@@ -0,0 +1,182 @@
+package m3u_test
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sherif-fanous/m3u"
+)
+
+const compactInput = `#EXTM3U url-tvg="http://127.0.0.1/epg.xml" tvg-shift='2'
+#PLAYLIST:Channels
+#EXTINF:-1 tvg-id="channel-1" tvg-name='Channel 1' tvg-language="English" tvg-logo="http://127.0.0.1/logos/1.png" group-title="News" tvg-chno=1,Channel 1
+#EXTVLCOPT:http-referrer=http://example.com/
+http://127.0.0.1/stream_1
+#EXTINF:-1 tvg-id="" group-title="News",Channel 2
+#EXTVLCOPT:http-referrer=http://example.com/
+http://127.0.0.1/stream_2
+#EXTINF:120.5,Movie
+#EXTGENRE:Drama
+#EXTIMG:posters/movie.jpg
+http://127.0.0.1/movie.mp4
+`
+
+func TestCompactPlaylist(t *testing.T) {
+	t.Parallel()
+
+	var playlist m3u.Playlist
+	if err := m3u.NewDecoder(strings.NewReader(compactInput), m3u.WithRecordedAttributeOrder()).Decode(&playlist); err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+
+	compact := m3u.NewCompactPlaylist(&playlist)
+	if compact.Len() != 3 {
+		t.Fatalf("Expected 3 tracks, got %d", compact.Len())
+	}
+
+	if diff := cmp.Diff(compact.Playlist(), &playlist); diff != "" {
+		t.Error(diff)
+	}
+
+	// Materialized tracks are copies
+	track := compact.Track(0)
+	*track.GroupTitle = "Sports"
+	track.ExtraAttributes["tvg-chno"] = "2"
+
+	if diff := cmp.Diff(compact.Track(0), playlist.Tracks[0]); diff != "" {
+		t.Error(diff)
+	}
+
+	compact.Append(track)
+	if diff := cmp.Diff(compact.Track(3), track); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestDecodeCompact(t *testing.T) {
+	t.Parallel()
+
+	playlist, err := m3u.Unmarshal([]byte(compactInput))
+	if err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+
+	var compact m3u.CompactPlaylist
+	if err := m3u.NewDecoder(strings.NewReader(compactInput)).DecodeCompact(&compact); err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+
+	if diff := cmp.Diff(compact.Playlist(), playlist); diff != "" {
+		t.Error(diff)
+	}
+
+	var buf bytes.Buffer
+	if err := m3u.NewEncoder(&buf, m3u.WithLossless()).EncodeCompact(&compact, m3u.M3UPlus); err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+
+	var expected bytes.Buffer
+	if err := m3u.NewEncoder(&expected, m3u.WithLossless()).Encode(playlist, m3u.M3UPlus); err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+
+	if diff := cmp.Diff(buf.String(), expected.String()); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestDecodeWithInterning(t *testing.T) {
+	t.Parallel()
+
+	playlist, err := m3u.Unmarshal([]byte(compactInput))
+	if err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+
+	var interned m3u.Playlist
+	if err := m3u.NewDecoder(strings.NewReader(compactInput), m3u.WithInterning()).Decode(&interned); err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+
+	if diff := cmp.Diff(&interned, playlist); diff != "" {
+		t.Error(diff)
+	}
+
+	first, second := interned.Tracks[0], interned.Tracks[1]
+
+	if unsafe.StringData(*first.GroupTitle) != unsafe.StringData(*second.GroupTitle) {
+		t.Error("Expected group titles to share memory")
+	}
+
+	if unsafe.StringData(first.ExtraDirectives[0]) != unsafe.StringData(second.ExtraDirectives[0]) {
+		t.Error("Expected directives to share memory")
+	}
+}
+
+// reportRetained reports the heap memory retained by the value returned by
+// decode.
+func reportRetained(b *testing.B, decode func() any) {
+	b.Helper()
+
+	var before, after runtime.MemStats
+
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	v := decode()
+
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(v)
+
+	b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc), "retained-B")
+}
+
+func BenchmarkDecodeRetained(b *testing.B) {
+	data := makeLargePlaylist(1000)
+
+	for b.Loop() {
+		reportRetained(b, func() any {
+			playlist, _ := m3u.Unmarshal(data)
+			return playlist
+		})
+	}
+}
+
+func BenchmarkDecodeWithInterning(b *testing.B) {
+	data := makeLargePlaylist(1000)
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		reportRetained(b, func() any {
+			var playlist m3u.Playlist
+			if err := m3u.NewDecoder(bytes.NewReader(data), m3u.WithInterning()).Decode(&playlist); err != nil {
+				b.Fatalf("Failed to decode: %v", err)
+			}
+
+			return &playlist
+		})
+	}
+}
+
+func BenchmarkDecodeCompact(b *testing.B) {
+	data := makeLargePlaylist(1000)
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		reportRetained(b, func() any {
+			var compact m3u.CompactPlaylist
+			if err := m3u.NewDecoder(bytes.NewReader(data)).DecodeCompact(&compact); err != nil {
+				b.Fatalf("Failed to decode: %v", err)
+			}
+
+			return &compact
+		})
+	}
+}
@@ -0,0 +1,269 @@
+package m3u
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// csvColumns lists the columns written for every track, before the columns of
+// extra attributes.
+var csvColumns = []string{
+	"name",
+	"length",
+	"url",
+	"tvg-id",
+	"tvg-name",
+	"tvg-language",
+	"tvg-logo",
+	"group-title",
+}
+
+// csvDirectivesColumn holds the extra directives of a track, one per line.
+const csvDirectivesColumn = "directives"
+
+// csvAlternateURLsColumn holds the alternate URLs of a track, one per line.
+const csvAlternateURLsColumn = "alternate-urls"
+
+// csvOptionalColumns lists the columns written after the columns of extra
+// attributes, only if a track has a value for them.
+var csvOptionalColumns = []string{
+	"album",
+	"artist",
+	"genre",
+	"image",
+	csvAlternateURLsColumn,
+	csvDirectivesColumn,
+}
+
+// WriteCSV writes the tracks of p to w as CSV records separated by comma, such
+// as ',' for CSV or '\t' for TSV. The header row names the columns: name,
+// length, url, tvg-id, tvg-name, tvg-language, tvg-logo and group-title,
+// followed by a column for every extra attribute found in the tracks, in
+// sorted order, and by the album, artist, genre, image, alternate-urls and
+// directives columns of the fields set in any track. Alternate URLs and
+// directives are written one per line. Unset fields are written as empty
+// cells. Playlist attributes are not written.
+func WriteCSV(w io.Writer, p *Playlist, comma rune) error {
+	header := csvHeader(p)
+
+	writer := csv.NewWriter(w)
+	writer.Comma = comma
+
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("failed to write csv: %w", err)
+	}
+
+	record := make([]string, len(header))
+
+	for i := range p.Tracks {
+		track := &p.Tracks[i]
+
+		for j, column := range header {
+			record[j] = csvField(track, column)
+		}
+
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write csv: %w", err)
+		}
+	}
+
+	writer.Flush()
+
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write csv: %w", err)
+	}
+
+	return nil
+}
+
+// ReadCSV reads a playlist written by WriteCSV from r, whose records are
+// separated by comma. Columns may appear in any order, and the names of the
+// track fields are matched case-insensitively. Columns other than the track
+// fields and of the optional columns become extra attributes. Empty cells leave their field
+// unset, and an empty length is read as -1.
+//
+// Cells that cannot be imported are reported as an InvalidCSVError.
+func ReadCSV(r io.Reader, comma rune) (*Playlist, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = comma
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return &Playlist{}, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid csv playlist: %w", err)
+	}
+
+	columns, err := csvHeaderColumns(header)
+	if err != nil {
+		return nil, err
+	}
+
+	playlist := &Playlist{}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid csv playlist: %w", err)
+		}
+
+		row, _ := reader.FieldPos(0)
+		track := Track{Length: -1}
+
+		for i, value := range record {
+			if value == "" {
+				continue
+			}
+
+			if err := setCSVField(&track, columns[i], value); err != nil {
+				return nil, InvalidCSVError{Message: err.Error(), Row: row, Column: header[i], Value: value}
+			}
+		}
+
+		playlist.Tracks = append(playlist.Tracks, track)
+	}
+
+	return playlist, nil
+}
+
+// csvHeader returns the columns written for the tracks of p.
+func csvHeader(p *Playlist) []string {
+	extras := make(map[string]bool)
+	optional := make(map[string]bool)
+
+	for i := range p.Tracks {
+		track := &p.Tracks[i]
+
+		for key := range track.ExtraAttributes {
+			extras[key] = true
+		}
+
+		for _, column := range csvOptionalColumns {
+			if csvField(track, column) != "" {
+				optional[column] = true
+			}
+		}
+	}
+
+	header := slices.Clone(csvColumns)
+	for _, key := range slices.Sorted(maps.Keys(extras)) {
+		if !slices.Contains(csvColumns, key) && !slices.Contains(csvOptionalColumns, key) {
+			header = append(header, key)
+		}
+	}
+
+	for _, column := range csvOptionalColumns {
+		if optional[column] {
+			header = append(header, column)
+		}
+	}
+
+	return header
+}
+
+// csvField returns the cell of the column for track.
+func csvField(track *Track, column string) string {
+	stringValue := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+
+		return *s
+	}
+
+	switch column {
+	case "album":
+		return stringValue(track.Album)
+	case "artist":
+		return stringValue(track.Artist)
+	case "genre":
+		return stringValue(track.Genre)
+	case "image":
+		if track.Image == nil {
+			return ""
+		}
+
+		return track.Image.String()
+	case csvAlternateURLsColumn:
+		urls := make([]string, len(track.AlternateURLs))
+		for i, u := range track.AlternateURLs {
+			urls[i] = u.String()
+		}
+
+		return strings.Join(urls, "\n")
+	case csvDirectivesColumn:
+		return strings.Join(track.ExtraDirectives, "\n")
+	default:
+		value, _ := trackField(track, column)
+		return value
+	}
+}
+
+// setCSVField sets the field of track read from the cell of the column.
+func setCSVField(track *Track, column string, value string) error {
+	switch column {
+	case "album":
+		track.Album = &value
+	case "artist":
+		track.Artist = &value
+	case "genre":
+		track.Genre = &value
+	case "image":
+		u, err := parseURL(value)
+		if err != nil {
+			return fmt.Errorf("invalid URL %q: %v", value, err)
+		}
+
+		track.Image = u
+	case csvAlternateURLsColumn:
+		for _, line := range strings.Split(value, "\n") {
+			u, err := parseURL(line)
+			if err != nil {
+				return fmt.Errorf("invalid URL %q: %v", line, err)
+			}
+
+			track.AlternateURLs = append(track.AlternateURLs, u)
+		}
+	case csvDirectivesColumn:
+		track.ExtraDirectives = strings.Split(value, "\n")
+	default:
+		return setTrackField(track, column, value)
+	}
+
+	return nil
+}
+
+// csvHeaderColumns maps the header row of a CSV playlist to track field names.
+func csvHeaderColumns(header []string) ([]string, error) {
+	columns := make([]string, len(header))
+	seen := make(map[string]bool, len(header))
+
+	for i, name := range header {
+		column := strings.TrimSpace(name)
+		if lower := strings.ToLower(column); slices.Contains(csvColumns, lower) || slices.Contains(csvOptionalColumns, lower) {
+			column = lower
+		}
+
+		switch {
+		case column == "":
+			return nil, InvalidCSVError{Message: "empty column name", Row: 1, Column: fmt.Sprint(i + 1), Value: name}
+		case seen[column]:
+			return nil, InvalidCSVError{Message: "duplicate column", Row: 1, Column: name, Value: name}
+		}
+
+		seen[column] = true
+		columns[i] = column
+	}
+
+	return columns, nil
+}
@@ -0,0 +1,179 @@
+package m3u_test
+
+import (
+	"bytes"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sherif-fanous/m3u"
+)
+
+func TestWriteCSV(t *testing.T) {
+	t.Parallel()
+
+	playlist := &m3u.Playlist{
+		Tracks: []m3u.Track{
+			{
+				Length:          -1,
+				Name:            "Channel 1, HD",
+				TVGID:           makePointer("channel-1"),
+				TVGLogo:         makeURL(t, "http://127.0.0.1/logos/1.png"),
+				GroupTitle:      makePointer("News"),
+				URL:             makeURL(t, "http://127.0.0.1/stream_1"),
+				ExtraAttributes: map[string]string{"tvg-country": "USA"},
+			},
+			{
+				Length:          120.5,
+				Name:            "Movie",
+				URL:             makeURL(t, "http://127.0.0.1/movie.mp4"),
+				ExtraAttributes: map[string]string{"tvg-chno": "2"},
+				ExtraDirectives: []string{"#EXTVLCOPT:http-referrer=http://example.com/", "#EXTGRP:Movies"},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := m3u.WriteCSV(&buf, playlist, ','); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+
+	expected := "name,length,url,tvg-id,tvg-name,tvg-language,tvg-logo,group-title,tvg-chno,tvg-country,directives\n" +
+		"\"Channel 1, HD\",-1,http://127.0.0.1/stream_1,channel-1,,,http://127.0.0.1/logos/1.png,News,,USA,\n" +
+		"Movie,120.5,http://127.0.0.1/movie.mp4,,,,,,2,,\"#EXTVLCOPT:http-referrer=http://example.com/\n#EXTGRP:Movies\"\n"
+
+	if diff := cmp.Diff(buf.String(), expected); diff != "" {
+		t.Error(diff)
+	}
+
+	decodedPlaylist, err := m3u.ReadCSV(&buf, ',')
+	if err != nil {
+		t.Fatalf("Failed to read CSV: %v", err)
+	}
+
+	if diff := cmp.Diff(decodedPlaylist, playlist); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestCSVMetadataAndAlternateURLs(t *testing.T) {
+	t.Parallel()
+
+	playlist := &m3u.Playlist{
+		Tracks: []m3u.Track{
+			{
+				Length: 215,
+				Name:   "Song 1",
+				URL:    makeURL(t, "music/01.mp3"),
+				Album:  makePointer("Album 1"),
+				Artist: makePointer("Artist 1"),
+				Genre:  makePointer("Blues"),
+				Image:  makeURL(t, "covers/album_1.jpg"),
+			},
+			{
+				Length: -1,
+				Name:   "Channel 1",
+				URL:    makeURL(t, "http://127.0.0.1/stream_1"),
+				AlternateURLs: []*url.URL{
+					makeURL(t, "http://127.0.0.2/stream_1"),
+					makeURL(t, "http://127.0.0.3/stream_1"),
+				},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := m3u.WriteCSV(&buf, playlist, ','); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+
+	expected := "name,length,url,tvg-id,tvg-name,tvg-language,tvg-logo,group-title,album,artist,genre,image,alternate-urls\n" +
+		"Song 1,215,music/01.mp3,,,,,,Album 1,Artist 1,Blues,covers/album_1.jpg,\n" +
+		"Channel 1,-1,http://127.0.0.1/stream_1,,,,,,,,,,\"http://127.0.0.2/stream_1\nhttp://127.0.0.3/stream_1\"\n"
+
+	if diff := cmp.Diff(buf.String(), expected); diff != "" {
+		t.Error(diff)
+	}
+
+	decodedPlaylist, err := m3u.ReadCSV(&buf, ',')
+	if err != nil {
+		t.Fatalf("Failed to read CSV: %v", err)
+	}
+
+	if diff := cmp.Diff(decodedPlaylist, playlist); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestReadTSV(t *testing.T) {
+	t.Parallel()
+
+	input := "Name\tURL\tGroup-Title\ttvg-rec\n" +
+		"Channel 1\thttp://127.0.0.1/stream_1\tNews\t3\n" +
+		"Channel 2\t\t\t\n"
+
+	playlist, err := m3u.ReadCSV(strings.NewReader(input), '\t')
+	if err != nil {
+		t.Fatalf("Failed to read TSV: %v", err)
+	}
+
+	expectedPlaylist := &m3u.Playlist{
+		Tracks: []m3u.Track{
+			{
+				Length:          -1,
+				Name:            "Channel 1",
+				GroupTitle:      makePointer("News"),
+				URL:             makeURL(t, "http://127.0.0.1/stream_1"),
+				ExtraAttributes: map[string]string{"tvg-rec": "3"},
+			},
+			{Length: -1, Name: "Channel 2"},
+		},
+	}
+
+	if diff := cmp.Diff(playlist, expectedPlaylist); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestErrInvalidCSV(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input    string
+		expected m3u.InvalidCSVError
+	}{
+		{
+			input: "name,length\nChannel 1,-1\nChannel 2,long\n",
+			expected: m3u.InvalidCSVError{
+				Message: `invalid length "long"`,
+				Row:     3,
+				Column:  "length",
+				Value:   "long",
+			},
+		},
+		{
+			input: "name,Name\n",
+			expected: m3u.InvalidCSVError{
+				Message: "duplicate column",
+				Row:     1,
+				Column:  "Name",
+				Value:   "Name",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		_, err := m3u.ReadCSV(strings.NewReader(test.input), ',')
+
+		var invErr m3u.InvalidCSVError
+		if !errors.As(err, &invErr) {
+			t.Fatalf("Expected InvalidCSVError, got: %v", err)
+		}
+
+		if diff := cmp.Diff(invErr, test.expected); diff != "" {
+			t.Error(diff)
+		}
+	}
+}
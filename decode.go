@@ -3,37 +3,240 @@ package m3u
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/url"
-	"regexp"
+	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 )
 
-// Regular expressions for parsing EXTINF lines
-var (
-	extm3uLineRegex = regexp.MustCompile(`^#EXTM3U(?:\s+(.*))?$`)
-	extinfLineRegex = regexp.MustCompile(
-		`^#EXTINF:(-?\d+\.?\d*)((?:\s+[\p{L}\p{N}-]+="[^"]*")*)\s*,(.*)$`,
-	)
-	attributeRegex = regexp.MustCompile(`([\p{L}\p{N}-]+)="([^"]*)"`)
-)
-
 // Decoder reads and decodes M3U playlists from an input stream.
 type Decoder struct {
 	r          *bufio.Reader
 	lineNumber int
+	bytesRead  int64
+
+	// onTrack, if set, is called after a track is appended to the playlist
+	// with the line numbers of its `#EXTINF` directive and its URL.
+	onTrack func(extinfLineNumber, urlLineNumber int)
+
+	warnings     []Warning
+	redactErrors bool
+
+	// interned maps strings to their interned copies when interning is
+	// enabled
+	interning bool
+	interned  map[string]string
+
+	recordOrder    bool
+	groupFallbacks bool
+
+	// base, if set, is the location track URLs are resolved against
+	base      *url.URL
+	filePaths bool
+
+	progress func(Progress)
+	limits   Limits
+
+	// line and attrs are reused between lines to save allocations
+	line  []byte
+	attrs []attribute
+}
+
+// DecoderOption configures a Decoder.
+type DecoderOption func(*Decoder)
+
+// WithRedactedErrors makes the decoder mask credentials found in the lines and
+// messages of the errors and warnings it reports, as done by RedactLine.
+func WithRedactedErrors() DecoderOption {
+	return func(d *Decoder) {
+		d.redactErrors = true
+	}
+}
+
+// WithInterning makes the decoder share the memory of repeated names,
+// attribute keys, attribute values and directives, such as the group titles
+// of large playlists, between tracks. Interned strings are copied out of the
+// lines they were read from, so that the lines can be freed.
+func WithInterning() DecoderOption {
+	return func(d *Decoder) {
+		d.interning = true
+	}
+}
+
+// WithRecordedAttributeOrder makes the decoder record the order of the
+// attributes of the header and tracks in their AttributeOrder fields, so that
+// encoders can reproduce it with AttributeOrderOriginal.
+func WithRecordedAttributeOrder() DecoderOption {
+	return func(d *Decoder) {
+		d.recordOrder = true
+	}
+}
+
+// WithFallbackGrouping makes the decoder merge consecutive #EXTINF directive
+// blocks that are identical but for their URLs, as providers list the backup
+// streams of a channel, into one track. The URL of the first block is the URL
+// of the track, and those of the others its AlternateURLs, in order. URL lines
+// that directly follow the URL of a block, as some formats allow multiple URLs
+// per entry, are read as AlternateURLs too.
+func WithFallbackGrouping() DecoderOption {
+	return func(d *Decoder) {
+		d.groupFallbacks = true
+	}
+}
+
+// WithBaseURL makes the decoder resolve the URLs of tracks against base, as
+// done by ResolveLocation, so that relative locations and Windows paths become
+// absolute URLs.
+func WithBaseURL(base *url.URL) DecoderOption {
+	return func(d *Decoder) {
+		d.base = base
+	}
+}
+
+// WithFilePaths makes the decoder keep the locations of tracks without a URL
+// scheme as file paths, as is in the Path of their URL, as do the playlists of
+// music players. Otherwise url.Parse would read characters such as '#', '%' and
+// '?' in file names as a fragment, an escape or a query. Locations with a
+// scheme are parsed as URLs. Encoders created with WithFilePathOutput write
+// these paths back unchanged.
+func WithFilePaths() DecoderOption {
+	return func(d *Decoder) {
+		d.filePaths = true
+	}
+}
+
+// WithBaseDir makes the decoder resolve the URLs of tracks against the file URL
+// of the directory dir, usually that of the playlist, as done by
+// ResolveLocation. Relative locations are file paths, and tracks get absolute
+// file URLs.
+func WithBaseDir(dir string) DecoderOption {
+	return func(d *Decoder) {
+		d.base = dirURL(dir)
+	}
+}
+
+// Limits bounds the resources a Decoder spends on a playlist. Zero values
+// mean no limit.
+type Limits struct {
+	// MaxLineLength is the maximum length of a line in bytes, excluding the
+	// line break.
+	MaxLineLength int
+	// MaxTracks is the maximum number of tracks. Alternate URLs grouped by
+	// WithFallbackGrouping count as tracks.
+	MaxTracks int
+	// MaxAttributes is the maximum number of attributes of an #EXTM3U or
+	// #EXTINF line.
+	MaxAttributes int
+	// MaxDirectives is the maximum number of extra directives of a track.
+	MaxDirectives int
+	// MaxBytes is the maximum size of the playlist in bytes.
+	MaxBytes int64
+}
+
+// WithLimits makes the decoder fail with a LimitError when the playlist
+// exceeds limits. Limits should be set when decoding untrusted input, as the
+// memory used by the decoder is otherwise only bounded by the input.
+func WithLimits(limits Limits) DecoderOption {
+	return func(d *Decoder) {
+		d.limits = limits
+	}
+}
+
+// Progress describes how far a Decoder has read its input.
+type Progress struct {
+	// Bytes is the number of bytes read.
+	Bytes int64
+	// Lines is the number of lines read.
+	Lines int
+	// Tracks is the number of tracks decoded.
+	Tracks int
+}
+
+// WithProgress makes the decoder call fn after decoding each track, and once
+// more when it reaches the end of the playlist.
+func WithProgress(fn func(Progress)) DecoderOption {
+	return func(d *Decoder) {
+		d.progress = fn
+	}
 }
 
 // NewDecoder returns a new decoder that reads from r.
-func NewDecoder(r io.Reader) *Decoder {
-	return &Decoder{r: bufio.NewReader(r)}
+func NewDecoder(r io.Reader, opts ...DecoderOption) *Decoder {
+	d := &Decoder{r: bufio.NewReader(r)}
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d
 }
 
 // Decode reads an M3U playlist from its input.
+//
+// Problems that Decode recovers from without losing data are reported by
+// Warnings.
 func (d *Decoder) Decode(playlist *Playlist) error {
+	return d.DecodeContext(context.Background(), playlist)
+}
+
+// DecodeContext is like Decode, but stops with the error of ctx when ctx is
+// done. The context is checked between lines; a read that blocks is only
+// interrupted by closing the input.
+func (d *Decoder) DecodeContext(ctx context.Context, playlist *Playlist) error {
+	return d.finish(d.decode(ctx, playlist, func(track Track) {
+		playlist.Tracks = append(playlist.Tracks, track)
+	}))
+}
+
+// DecodeCompact reads an M3U playlist from its input into c, without
+// materializing its tracks. It otherwise behaves like Decode.
+func (d *Decoder) DecodeCompact(c *CompactPlaylist) error {
+	return d.DecodeCompactContext(context.Background(), c)
+}
+
+// DecodeCompactContext is like DecodeCompact, but stops with the error of ctx
+// when ctx is done, as DecodeContext does.
+func (d *Decoder) DecodeCompactContext(ctx context.Context, c *CompactPlaylist) error {
+	c.Reset()
+
+	return d.finish(d.decode(ctx, &c.Header, c.Append))
+}
+
+// finish applies the decoder options to the error returned by decode and to
+// the warnings.
+func (d *Decoder) finish(err error) error {
+	if d.redactErrors {
+		for i := range d.warnings {
+			d.warnings[i].Message = RedactLine(d.warnings[i].Message)
+			d.warnings[i].Line = RedactLine(d.warnings[i].Line)
+			d.warnings[i].Value = RedactLine(d.warnings[i].Value)
+		}
+
+		var invErr InvalidPlaylistError
+		if errors.As(err, &invErr) {
+			invErr.Message = RedactLine(invErr.Message)
+			invErr.Line = RedactLine(invErr.Line)
+
+			return invErr
+		}
+	}
+
+	return err
+}
+
+// decode reads the playlist header into playlist, and passes each track to
+// add.
+func (d *Decoder) decode(ctx context.Context, playlist *Playlist, add func(Track)) error {
 	*playlist = Playlist{}
+	d.warnings = nil
+
+	if d.interning {
+		d.interned = make(map[string]string)
+	}
 
 	// Read #EXTM3U header
 	line, err := d.readLine()
@@ -46,6 +249,8 @@ func (d *Decoder) Decode(playlist *Playlist) error {
 			Message:    "playlist must start with the `#EXTM3U` directive",
 			LineNumber: d.lineNumber,
 			Line:       line,
+			Kind:       ErrorKindMissingHeader,
+			Column:     1,
 		}
 	}
 
@@ -53,9 +258,34 @@ func (d *Decoder) Decode(playlist *Playlist) error {
 		return err
 	}
 
-	var currentTrack *Track
+	var (
+		currentTrack     *Track
+		extinfLineNumber int
+		tracks           int
+		// entries counts the #EXTINF directive blocks, which differs from
+		// tracks when grouping fallbacks
+		entries int
+		// pending is the last track when grouping fallbacks, which is added
+		// once a different entry follows
+		pending *Track
+		// followsURL reports whether the previous line was a URL line
+		followsURL bool
+		// upcoming holds the metadata directives read between tracks, which
+		// apply to the next track, and upcomingLine the first of them
+		upcoming           Track
+		upcomingLine       string
+		upcomingLineNumber int
+	)
+
+	done := ctx.Done()
 
 	for {
+		select {
+		case <-done:
+			return ctx.Err()
+		default:
+		}
+
 		line, err := d.readLine()
 
 		// Handle empty lines with special EOF case
@@ -67,6 +297,8 @@ func (d *Decoder) Decode(playlist *Playlist) error {
 						Message:    "`#EXTINF` directive block must end with a URL",
 						LineNumber: d.lineNumber,
 						Line:       line,
+						Kind:       ErrorKindMissingURL,
+						Column:     1,
 					}
 				}
 
@@ -80,45 +312,136 @@ func (d *Decoder) Decode(playlist *Playlist) error {
 			continue // Just an empty line, skip it
 		}
 
+		afterURL := followsURL
+		followsURL = false
+
+		// Metadata directives between tracks apply to the next track
+		metadataTrack := currentTrack
+		if metadataTrack == nil && entries > 0 {
+			metadataTrack = &upcoming
+		}
+
 		if strings.HasPrefix(line, "#EXTINF:") {
 			if currentTrack != nil {
 				return InvalidPlaylistError{
 					Message:    "`#EXTINF` directive block must end with a URL",
 					LineNumber: d.lineNumber,
 					Line:       line,
+					Kind:       ErrorKindMissingURL,
+					Column:     1,
 				}
 			}
 
+			if limit := d.limits.MaxTracks; limit > 0 && entries >= limit {
+				return d.limitError("tracks", int64(limit))
+			}
+
 			// Parse new track
 			var track Track
 			if err := d.parseEXTINFLine(line, &track); err != nil {
 				return err
 			}
 
+			track.Album, track.Artist, track.Genre, track.Image = upcoming.Album, upcoming.Artist, upcoming.Genre, upcoming.Image
+			track.ExtraDirectives = upcoming.ExtraDirectives
+			upcoming, upcomingLine, upcomingLineNumber = Track{}, "", 0
+
 			currentTrack = &track
+			extinfLineNumber = d.lineNumber
+		} else if m := metadataOf(playlist, metadataTrack, entries); m.has(line) {
+			if metadataTrack == &upcoming && upcomingLineNumber == 0 {
+				upcomingLine, upcomingLineNumber = line, d.lineNumber
+			}
+
+			if err := d.setMetadata(line, m); err != nil {
+				if metadataTrack == nil {
+					return InvalidPlaylistError{
+						Message:    fmt.Sprintf("invalid `#EXTIMG` URL: %v", err),
+						LineNumber: d.lineNumber,
+						Line:       line,
+						Kind:       ErrorKindInvalidURL,
+						Column:     len("#EXTIMG:") + 1,
+					}
+				}
+
+				// Like invalid tvg-logo attributes, invalid images of tracks
+				// are kept, as extra directives
+				d.warnings = append(d.warnings, Warning{
+					Message:    fmt.Sprintf("invalid `#EXTIMG` URL kept as an extra directive: %v", err),
+					LineNumber: d.lineNumber,
+					Line:       line,
+					Attribute:  "#EXTIMG",
+					Value:      strings.TrimPrefix(line, "#EXTIMG:"),
+				})
+
+				if err := d.addDirective(metadataTrack, line); err != nil {
+					return err
+				}
+			}
 		} else if strings.HasPrefix(line, "#") {
 			if currentTrack == nil {
 				return InvalidPlaylistError{
 					Message:    "`#EXTINF` directive must appear before any other directive",
 					LineNumber: d.lineNumber,
 					Line:       line,
+					Kind:       ErrorKindOrphanDirective,
+					Column:     1,
 				}
 			}
+
 			// It's a directive, add to extra directives
-			currentTrack.ExtraDirectives = append(currentTrack.ExtraDirectives, line)
-		} else if currentTrack != nil && currentTrack.URL == nil {
-			// This should be the URL line for the current track
-			parsedURL, err := url.Parse(line)
+			if err := d.addDirective(currentTrack, line); err != nil {
+				return err
+			}
+		} else if currentTrack != nil || (afterURL && d.groupFallbacks) {
+			// This should be the URL line for the current track or, when
+			// grouping fallbacks, an alternate URL of the previous one
+			if currentTrack == nil {
+				if limit := d.limits.MaxTracks; limit > 0 && entries >= limit {
+					return d.limitError("tracks", int64(limit))
+				}
+			}
+
+			parsedURL, err := d.location(line)
 			if err != nil {
 				return InvalidPlaylistError{
 					Message:    fmt.Sprintf("invalid URL: %v", err),
 					LineNumber: d.lineNumber,
 					Line:       line,
+					Kind:       ErrorKindInvalidURL,
+					Column:     1,
 				}
 			}
 
-			currentTrack.URL = parsedURL
-			playlist.Tracks = append(playlist.Tracks, *currentTrack)
+			entries++
+			followsURL = true
+
+			if currentTrack != nil {
+				currentTrack.URL = parsedURL
+			}
+
+			switch {
+			case !d.groupFallbacks:
+				add(*currentTrack)
+				tracks++
+			case currentTrack == nil, pending != nil && sameEntry(pending, currentTrack):
+				pending.AlternateURLs = append(pending.AlternateURLs, parsedURL)
+			default:
+				if pending != nil {
+					add(*pending)
+					tracks++
+				}
+
+				pending = currentTrack
+			}
+
+			if d.progress != nil {
+				d.progress(Progress{Bytes: d.bytesRead, Lines: d.lineNumber, Tracks: tracks})
+			}
+
+			if d.onTrack != nil && currentTrack != nil {
+				d.onTrack(extinfLineNumber, d.lineNumber)
+			}
 
 			// Reset for the next track
 			currentTrack = nil
@@ -127,6 +450,8 @@ func (d *Decoder) Decode(playlist *Playlist) error {
 				Message:    "unexpected content",
 				LineNumber: d.lineNumber,
 				Line:       line,
+				Kind:       ErrorKindUnexpectedContent,
+				Column:     1,
 			}
 		}
 
@@ -136,9 +461,34 @@ func (d *Decoder) Decode(playlist *Playlist) error {
 		}
 	}
 
+	if upcomingLineNumber != 0 {
+		return InvalidPlaylistError{
+			Message:    "track metadata must be followed by an `#EXTINF` directive",
+			LineNumber: upcomingLineNumber,
+			Line:       upcomingLine,
+			Kind:       ErrorKindOrphanDirective,
+			Column:     1,
+		}
+	}
+
+	if pending != nil {
+		add(*pending)
+		tracks++
+	}
+
+	if d.progress != nil {
+		d.progress(Progress{Bytes: d.bytesRead, Lines: d.lineNumber, Tracks: tracks})
+	}
+
 	return nil
 }
 
+// Warnings returns the problems the decoder recovered from during the last call
+// to Decode.
+func (d *Decoder) Warnings() []Warning {
+	return d.warnings
+}
+
 // Unmarshal parses the M3U-encoded data and returns the playlist.
 func Unmarshal(data []byte) (*Playlist, error) {
 	playlist := &Playlist{}
@@ -152,32 +502,49 @@ func Unmarshal(data []byte) (*Playlist, error) {
 }
 
 func (d *Decoder) parseEXTINFLine(line string, track *Track) error {
-	// Match the basic pattern first
-	matches := extinfLineRegex.FindStringSubmatch(line)
-	if matches == nil {
+	length, name, attrs, m := lexEXTINF(line, d.attrs[:0], d.limits.MaxAttributes)
+	d.attrs = attrs
+
+	if m != nil {
 		return InvalidPlaylistError{
 			Message: fmt.Sprintf(
 				"malformed `#EXTINF` line: `#EXTNF` line failed to match regex %q",
-				extinfLineRegex,
+				extinfLineGrammar,
 			),
 			LineNumber: d.lineNumber,
 			Line:       line,
+			Kind:       ErrorKindMalformedEXTINF,
+			Column:     m.offset + 1,
+			Attribute:  m.attribute,
+		}
+	}
+
+	if err := d.checkAttributes(attrs); err != nil {
+		return err
+	}
+
+	// The lexer only accepts valid floats, which may still be out of range
+	parsedLength, err := strconv.ParseFloat(length, 64)
+	if err != nil {
+		return InvalidPlaylistError{
+			Message:    fmt.Sprintf("malformed `#EXTINF` line: length %q is out of range", length),
+			LineNumber: d.lineNumber,
+			Line:       line,
+			Kind:       ErrorKindMalformedEXTINF,
+			Column:     len("#EXTINF:") + 1,
 		}
 	}
 
-	// Parse duration
-	// Intentionally ignore errors here, as the regex should have matched a valid float
-	length, _ := strconv.ParseFloat(matches[1], 64)
-	track.Length = length
+	track.Length = parsedLength
 
-	// Get the attributes part (between duration and name)
-	attributes := strings.TrimSpace(matches[2])
+	for _, attr := range attrs {
+		key, value, quoting := d.intern(attr.key), d.intern(attr.value), attr.quoting
 
-	// Extract all attributes
-	matchedAttributes := attributeRegex.FindAllStringSubmatch(attributes, -1)
-	for _, match := range matchedAttributes {
-		key := match[1]
-		value := match[2]
+		setQuoting(&track.AttributeQuoting, key, quoting)
+
+		if d.recordOrder {
+			track.AttributeOrder = appendKey(track.AttributeOrder, key)
+		}
 
 		switch key {
 		case "tvg-id":
@@ -187,9 +554,19 @@ func (d *Decoder) parseEXTINFLine(line string, track *Track) error {
 		case "tvg-language":
 			track.TVGLanguage = &value
 		case "tvg-logo":
-			if logoURL, err := url.Parse(value); err == nil {
-				track.TVGLogo = logoURL
+			logoURL, err := parseURL(value)
+			if err != nil {
+				d.warnInvalidURL(line, key, value, err)
+
+				if track.ExtraAttributes == nil {
+					track.ExtraAttributes = make(map[string]string)
+				}
+				track.ExtraAttributes[key] = value
+
+				continue
 			}
+
+			track.TVGLogo = logoURL
 		case "group-title":
 			track.GroupTitle = &value
 		default:
@@ -200,43 +577,60 @@ func (d *Decoder) parseEXTINFLine(line string, track *Track) error {
 		}
 	}
 
-	// Set name (after the last comma)
-	track.Name = strings.TrimSpace(matches[3])
+	track.Name = d.intern(name)
 
 	return nil
 }
 
 func (d *Decoder) parseEXTM3ULine(line string, playlist *Playlist) error {
-	// Match the basic pattern first
-	matches := extm3uLineRegex.FindStringSubmatch(line)
-	if matches == nil {
+	attrs, m := lexEXTM3U(line, d.attrs[:0], d.limits.MaxAttributes)
+	d.attrs = attrs
+
+	if m != nil {
 		return InvalidPlaylistError{
 			Message: fmt.Sprintf(
 				"malformed `#EXTM3U` line: `#EXTM3U` line failed to match regex %q",
-				extm3uLineRegex,
+				extm3uLineGrammar,
 			),
 			LineNumber: d.lineNumber,
 			Line:       line,
+			Kind:       ErrorKindMalformedHeader,
+			Column:     m.offset + 1,
+			Attribute:  m.attribute,
 		}
 	}
 
-	// Get the attributes part
-	attributes := strings.TrimSpace(matches[1])
+	if err := d.checkAttributes(attrs); err != nil {
+		return err
+	}
 
-	// Extract all attributes
-	matchedAttributes := attributeRegex.FindAllStringSubmatch(attributes, -1)
-	for _, match := range matchedAttributes {
-		key := match[1]
-		value := match[2]
+	for _, attr := range attrs {
+		key, value, quoting := d.intern(attr.key), d.intern(attr.value), attr.quoting
+
+		setQuoting(&playlist.AttributeQuoting, key, quoting)
+
+		if d.recordOrder {
+			playlist.AttributeOrder = appendKey(playlist.AttributeOrder, key)
+		}
 
 		switch key {
-		case "url-tvg":
-			if tvgURL, err := url.Parse(value); err == nil {
-				playlist.TVGURL = tvgURL
+		case "url-tvg", "x-tvg-url":
+			u, err := parseURL(value)
+			if err != nil {
+				d.warnInvalidURL(line, key, value, err)
+
+				if playlist.ExtraAttributes == nil {
+					playlist.ExtraAttributes = make(map[string]string)
+				}
+				playlist.ExtraAttributes[key] = value
+
+				continue
 			}
-		case "x-tvg-url":
-			if xTVGURL, err := url.Parse(value); err == nil {
-				playlist.XTVGURL = xTVGURL
+
+			if key == "url-tvg" {
+				playlist.TVGURL = u
+			} else {
+				playlist.XTVGURL = u
 			}
 		default:
 			if playlist.ExtraAttributes == nil {
@@ -249,13 +643,276 @@ func (d *Decoder) parseEXTM3ULine(line string, playlist *Playlist) error {
 	return nil
 }
 
+// sameEntry reports whether the tracks a and b are identical but for their
+// URLs.
+func sameEntry(a, b *Track) bool {
+	x, y := *a, *b
+	x.URL, x.AlternateURLs = nil, nil
+	y.URL, y.AlternateURLs = nil, nil
+
+	return reflect.DeepEqual(x, y)
+}
+
+// metadata points to the fields set by the #PLAYLIST, #EXTALB, #EXTART,
+// #EXTGENRE and #EXTIMG directives of a playlist or track. Fields of directives
+// that do not apply are nil.
+type metadata struct {
+	title  **string
+	album  **string
+	artist **string
+	genre  **string
+	image  **url.URL
+}
+
+// metadataOf returns the metadata the directives read next apply to: those of
+// track, inside its #EXTINF directive block or between the previous track and
+// it, and those of playlist before its first track. Tracks have no title, so their #PLAYLIST directives are kept as
+// extra directives.
+func metadataOf(playlist *Playlist, track *Track, entries int) metadata {
+	switch {
+	case track != nil:
+		return metadata{album: &track.Album, artist: &track.Artist, genre: &track.Genre, image: &track.Image}
+	case entries == 0:
+		return metadata{
+			title:  &playlist.Title,
+			album:  &playlist.Album,
+			artist: &playlist.Artist,
+			genre:  &playlist.Genre,
+			image:  &playlist.Image,
+		}
+	default:
+		return metadata{}
+	}
+}
+
+// field returns the text field set by the directive name, or nil if it has
+// none.
+func (m metadata) field(name string) **string {
+	switch name {
+	case "#PLAYLIST":
+		return m.title
+	case "#EXTALB":
+		return m.album
+	case "#EXTART":
+		return m.artist
+	case "#EXTGENRE":
+		return m.genre
+	default:
+		return nil
+	}
+}
+
+// has reports whether line is a directive setting a field of m.
+func (m metadata) has(line string) bool {
+	name, _, ok := strings.Cut(line, ":")
+	if !ok {
+		return false
+	}
+
+	if name == "#EXTIMG" {
+		return m.image != nil
+	}
+
+	return m.field(name) != nil
+}
+
+// addDirective adds the directive line to the extra directives of track.
+func (d *Decoder) addDirective(track *Track, line string) error {
+	if limit := d.limits.MaxDirectives; limit > 0 && len(track.ExtraDirectives) >= limit {
+		return d.limitError("directives per track", int64(limit))
+	}
+
+	track.ExtraDirectives = append(track.ExtraDirectives, d.intern(line))
+
+	return nil
+}
+
+// setMetadata sets the field of m named by the directive line, for which
+// m.has reports true. Later directives replace earlier ones. #EXTIMG
+// directives without a location are ignored, and those whose location fails
+// to parse return the error of the parser.
+func (d *Decoder) setMetadata(line string, m metadata) error {
+	name, value, _ := strings.Cut(line, ":")
+
+	if name != "#EXTIMG" {
+		value = d.intern(value)
+		*m.field(name) = &value
+
+		return nil
+	}
+
+	if value == "" {
+		return nil
+	}
+
+	image, err := d.location(value)
+	if err != nil {
+		return err
+	}
+
+	*m.image = image
+
+	return nil
+}
+
+// setQuoting records the quoting of the attribute key in *m. Double quoting is
+// the default and is not recorded, and replaces the quoting of an earlier
+// attribute with the same key, whose value was replaced too.
+func setQuoting(m *map[string]Quoting, key string, quoting Quoting) {
+	if quoting == DoubleQuoted {
+		delete(*m, key)
+		if len(*m) == 0 {
+			*m = nil
+		}
+
+		return
+	}
+
+	if *m == nil {
+		*m = make(map[string]Quoting)
+	}
+	(*m)[key] = quoting
+}
+
+// appendKey appends key to keys unless an earlier attribute has the same key.
+func appendKey(keys []string, key string) []string {
+	if slices.Contains(keys, key) {
+		return keys
+	}
+
+	return append(keys, key)
+}
+
+// unescapeValue removes the backslash from escaped quote and backslash
+// characters in a value quoted with quote. Other backslashes are kept as is.
+func unescapeValue(value string, quote byte) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+
+	var b strings.Builder
+
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) && (value[i+1] == quote || value[i+1] == '\\') {
+			i++
+		}
+
+		b.WriteByte(value[i])
+	}
+
+	return b.String()
+}
+
+// parseURL parses s like url.Parse, but drops the raw encodings of the path
+// and fragment when String does not use them because they are invalid, so
+// that decoding the encoding of a URL yields an equal URL.
+func parseURL(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.RawPath != "" && u.EscapedPath() != u.RawPath {
+		u.RawPath = ""
+	}
+
+	if u.RawFragment != "" && u.EscapedFragment() != u.RawFragment {
+		u.RawFragment = ""
+	}
+
+	return u, nil
+}
+
+// location parses the URL of a track, resolving it against the base of the
+// decoder if any.
+func (d *Decoder) location(s string) (*url.URL, error) {
+	switch {
+	case d.base != nil:
+		return ResolveLocation(s, d.base)
+	case d.filePaths:
+		return parseLocation(s)
+	default:
+		return parseURL(s)
+	}
+}
+
+// intern returns the interned copy of s when interning is enabled, or s
+// otherwise.
+func (d *Decoder) intern(s string) string {
+	if !d.interning {
+		return s
+	}
+
+	if interned, ok := d.interned[s]; ok {
+		return interned
+	}
+
+	s = strings.Clone(s)
+	d.interned[s] = s
+
+	return s
+}
+
+// checkAttributes enforces the limit on the number of attributes of a line,
+// which the lexer stops scanning at once exceeded.
+func (d *Decoder) checkAttributes(attrs []attribute) error {
+	if limit := d.limits.MaxAttributes; limit > 0 && len(attrs) > limit {
+		return d.limitError("attributes per line", int64(limit))
+	}
+
+	return nil
+}
+
+func (d *Decoder) limitError(name string, limit int64) error {
+	return LimitError{Limit: name, Max: limit, LineNumber: d.lineNumber}
+}
+
+// warnInvalidURL records that the value of a URL attribute failed to parse and
+// was kept as an extra attribute instead.
+func (d *Decoder) warnInvalidURL(line, key, value string, err error) {
+	d.warnings = append(d.warnings, Warning{
+		Message:    fmt.Sprintf("invalid `%s` URL kept as an extra attribute: %v", key, err),
+		LineNumber: d.lineNumber,
+		Line:       line,
+		Attribute:  key,
+		Value:      value,
+	})
+}
+
+// readLine returns the next line with surrounding whitespace removed. Lines
+// are read in place from the buffered reader, and only copied when they do not
+// fit in its buffer. The line length and size limits are enforced before the
+// line is read in full.
 func (d *Decoder) readLine() (string, error) {
 	d.lineNumber++
+	d.line = d.line[:0]
 
-	line, err := d.r.ReadString('\n')
-	if err != nil && err != io.EOF {
-		return "", fmt.Errorf("error reading line: %w", err)
-	}
+	for {
+		chunk, err := d.r.ReadSlice('\n')
+		d.bytesRead += int64(len(chunk))
+
+		if limit := d.limits.MaxBytes; limit > 0 && d.bytesRead > limit {
+			return "", d.limitError("playlist size", limit)
+		}
+
+		if limit := d.limits.MaxLineLength; limit > 0 && len(d.line)+len(bytes.TrimRight(chunk, "\r\n")) > limit {
+			return "", d.limitError("line length", int64(limit))
+		}
 
-	return strings.TrimSpace(line), err
+		if err == bufio.ErrBufferFull {
+			d.line = append(d.line, chunk...)
+			continue
+		}
+
+		if err != nil && err != io.EOF {
+			return "", fmt.Errorf("error reading line: %w", err)
+		}
+
+		if len(d.line) > 0 {
+			chunk = append(d.line, chunk...)
+			d.line = chunk
+		}
+
+		return string(bytes.TrimSpace(chunk)), err
+	}
 }
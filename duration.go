@@ -0,0 +1,70 @@
+package m3u
+
+import (
+	"math"
+	"time"
+)
+
+// LiveLength is the conventional length of tracks without a known duration,
+// such as live streams.
+const LiveLength = -1
+
+// IsLive reports whether the track has no known duration, as live streams.
+// Players write -1 for these, and sometimes 0, so any length that is not
+// positive is live.
+func (t *Track) IsLive() bool {
+	return !(t.Length > 0)
+}
+
+// Duration returns the length of the track as a duration, or 0 if the track is
+// live. Lengths too long for a duration are capped.
+func (t *Track) Duration() time.Duration {
+	if t.IsLive() {
+		return 0
+	}
+
+	if seconds := t.Length; seconds < math.MaxInt64/float64(time.Second) {
+		return time.Duration(math.Round(seconds * float64(time.Second)))
+	}
+
+	return math.MaxInt64
+}
+
+// SetDuration sets the length of the track to d. A duration that is not
+// positive marks the track as live, with the length LiveLength.
+func (t *Track) SetDuration(d time.Duration) {
+	if d <= 0 {
+		t.Length = LiveLength
+		return
+	}
+
+	t.Length = d.Seconds()
+}
+
+// TotalDuration returns the sum of the durations of the tracks that are not
+// live.
+func (p *Playlist) TotalDuration() time.Duration {
+	var total time.Duration
+
+	for i := range p.Tracks {
+		d := p.Tracks[i].Duration()
+		if total > math.MaxInt64-d {
+			return math.MaxInt64
+		}
+
+		total += d
+	}
+
+	return total
+}
+
+// integerLength returns length rounded to whole seconds. Lengths of finite
+// tracks are not rounded below 1, so that they are not read as live.
+func integerLength(length float64) float64 {
+	rounded := math.Round(length)
+	if length > 0 && rounded < 1 {
+		return 1
+	}
+
+	return rounded
+}
@@ -0,0 +1,97 @@
+package m3u_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sherif-fanous/m3u"
+)
+
+func TestTrackDuration(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		length   float64
+		live     bool
+		duration time.Duration
+	}{
+		{length: -1, live: true},
+		{length: 0, live: true},
+		{length: -2.5, live: true},
+		{length: 215, duration: 215 * time.Second},
+		{length: 123.5, duration: 123*time.Second + 500*time.Millisecond},
+		{length: 1e300, duration: time.Duration(1<<63 - 1)},
+	}
+
+	for _, test := range tests {
+		track := m3u.Track{Length: test.length}
+
+		if track.IsLive() != test.live {
+			t.Errorf("Expected IsLive of %v to be %t", test.length, test.live)
+		}
+
+		if d := track.Duration(); d != test.duration {
+			t.Errorf("Expected duration of %v to be %v, got %v", test.length, test.duration, d)
+		}
+	}
+}
+
+func TestTrackSetDuration(t *testing.T) {
+	t.Parallel()
+
+	var track m3u.Track
+
+	track.SetDuration(2*time.Minute + 3500*time.Millisecond)
+	if track.Length != 123.5 {
+		t.Errorf("Expected length 123.5, got %v", track.Length)
+	}
+
+	track.SetDuration(0)
+	if track.Length != m3u.LiveLength || !track.IsLive() {
+		t.Errorf("Expected a live track, got length %v", track.Length)
+	}
+}
+
+func TestTotalDuration(t *testing.T) {
+	t.Parallel()
+
+	playlist := &m3u.Playlist{
+		Tracks: []m3u.Track{{Length: 215}, {Length: -1}, {Length: 0}, {Length: 120.5}},
+	}
+
+	if total := playlist.TotalDuration(); total != 335500*time.Millisecond {
+		t.Errorf("Expected 5m35.5s, got %v", total)
+	}
+}
+
+func TestEncodeIntegerLengths(t *testing.T) {
+	t.Parallel()
+
+	playlist := &m3u.Playlist{
+		Tracks: []m3u.Track{
+			{Length: 123.5, Name: "Song 1", URL: makeURL(t, "01.mp3")},
+			{Length: 0.2, Name: "Jingle", URL: makeURL(t, "02.mp3")},
+			{Length: -1, Name: "Radio", URL: makeURL(t, "http://127.0.0.1/radio.mp3")},
+		},
+	}
+
+	var b strings.Builder
+	if err := m3u.NewEncoder(&b, m3u.WithIntegerLengths()).Encode(playlist, m3u.M3U); err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+
+	expected := `#EXTM3U
+#EXTINF:124,Song 1
+01.mp3
+#EXTINF:1,Jingle
+02.mp3
+#EXTINF:-1,Radio
+http://127.0.0.1/radio.mp3
+`
+
+	if diff := cmp.Diff(b.String(), expected); diff != "" {
+		t.Error(diff)
+	}
+}
@@ -1,70 +1,370 @@
 package m3u
 
 import (
+	"bufio"
 	"bytes"
+	"cmp"
+	"errors"
 	"fmt"
 	"io"
 	"maps"
 	"net/url"
 	"slices"
 	"strconv"
+	"strings"
 )
 
 // Encoder writes M3U playlists to an output stream.
+//
+// A playlist is written either at once with Encode, or incrementally with
+// EncodeHeader followed by EncodeTrack for each track and a final Flush. The
+// output is buffered, and the first error encountered is returned by every
+// following call.
 type Encoder struct {
-	w   io.Writer
-	err error
+	w        *bufio.Writer
+	err      error
+	lossless bool
+
+	// headerWritten reports whether the #EXTM3U line was written, which must
+	// precede the tracks
+	headerWritten bool
+
+	lineEnding     LineEnding
+	m3uDirectives  bool
+	integerLengths bool
+	alternateURLs  AlternateURLMode
+
+	attributeOrder AttributeOrder
+	customOrder    []string
+	included       map[string]bool
+	excluded       map[string]bool
+
+	// relativeTo, if set, is the location track URLs are written relative to
+	relativeTo *url.URL
+	filePaths  bool
+
+	// attrs is reused between lines to save allocations
+	attrs []encodedAttribute
+}
+
+// EncoderOption configures an Encoder.
+type EncoderOption func(*Encoder)
+
+// LineEnding is the sequence of characters ending each line written by an
+// Encoder.
+type LineEnding string
+
+const (
+	// LF ends lines with a line feed, as is usual on Unix systems.
+	LF LineEnding = "\n"
+	// CRLF ends lines with a carriage return and a line feed, as expected by
+	// some Windows programs and set-top boxes.
+	CRLF LineEnding = "\r\n"
+)
+
+// WithLineEnding makes the encoder end lines with ending instead of LF.
+func WithLineEnding(ending LineEnding) EncoderOption {
+	return func(e *Encoder) {
+		e.lineEnding = ending
+	}
+}
+
+// WithM3UDirectives sets whether the encoder writes the #EXTALB, #EXTART,
+// #EXTGENRE, #EXTIMG and extra directives of tracks in the M3U format. They are written by default; the M3UPlus format
+// always includes them.
+func WithM3UDirectives(write bool) EncoderOption {
+	return func(e *Encoder) {
+		e.m3uDirectives = write
+	}
+}
+
+// WithIntegerLengths makes the encoder round the lengths of tracks to whole
+// seconds, for legacy players that reject fractional lengths such as
+// #EXTINF:123.5. Lengths of finite tracks are not rounded below 1 second.
+func WithIntegerLengths() EncoderOption {
+	return func(e *Encoder) {
+		e.integerLengths = true
+	}
+}
+
+// AlternateURLMode selects how an Encoder writes the AlternateURLs of tracks,
+// which have no place in the M3U format.
+type AlternateURLMode int
+
+const (
+	// AlternateURLsFlatten writes every alternate URL as a separate entry
+	// following that of the track, with the same #EXTINF line and
+	// directives. WithFallbackGrouping merges these entries back.
+	AlternateURLsFlatten AlternateURLMode = iota
+	// AlternateURLsPrimary only writes the URL of the track.
+	AlternateURLsPrimary
+)
+
+// WithAlternateURLMode makes the encoder write alternate URLs with mode instead
+// of AlternateURLsFlatten.
+func WithAlternateURLMode(mode AlternateURLMode) EncoderOption {
+	return func(e *Encoder) {
+		e.alternateURLs = mode
+	}
+}
+
+// AttributeOrder selects the order in which an Encoder writes attributes.
+type AttributeOrder int
+
+const (
+	// AttributeOrderFixed writes the built-in attributes first, in the order
+	// url-tvg, x-tvg-url for the header and tvg-id, tvg-name, tvg-language,
+	// tvg-logo, group-title for tracks, followed by the extra attributes
+	// sorted by name.
+	AttributeOrderFixed AttributeOrder = iota
+	// AttributeOrderSorted writes all attributes sorted by name.
+	AttributeOrderSorted
+	// AttributeOrderOriginal writes attributes in the order recorded in the
+	// AttributeOrder fields of the header and tracks by a decoder created with
+	// WithRecordedAttributeOrder. Attributes missing from the recorded order
+	// follow in the fixed order.
+	AttributeOrderOriginal
+)
+
+// WithAttributeOrder makes the encoder write attributes in order instead of
+// AttributeOrderFixed.
+func WithAttributeOrder(order AttributeOrder) EncoderOption {
+	return func(e *Encoder) {
+		e.attributeOrder = order
+	}
+}
+
+// WithCustomAttributeOrder makes the encoder write the attributes named by
+// keys first, in the given order. The other attributes follow in the order
+// selected with WithAttributeOrder.
+func WithCustomAttributeOrder(keys ...string) EncoderOption {
+	return func(e *Encoder) {
+		e.customOrder = keys
+	}
+}
+
+// WithIncludedAttributes makes the encoder only write the attributes named by
+// keys, of the header and tracks alike.
+func WithIncludedAttributes(keys ...string) EncoderOption {
+	return func(e *Encoder) {
+		e.included = make(map[string]bool, len(keys))
+		for _, key := range keys {
+			e.included[key] = true
+		}
+	}
+}
+
+// WithExcludedAttributes makes the encoder omit the attributes named by keys,
+// of the header and tracks alike.
+func WithExcludedAttributes(keys ...string) EncoderOption {
+	return func(e *Encoder) {
+		e.excluded = make(map[string]bool, len(keys))
+		for _, key := range keys {
+			e.excluded[key] = true
+		}
+	}
+}
+
+// WithRelativeTo makes the encoder write the URLs of tracks relative to base
+// where possible, as done by RelativeLocation. It reverses WithBaseURL.
+func WithRelativeTo(base *url.URL) EncoderOption {
+	return func(e *Encoder) {
+		e.relativeTo = base
+	}
+}
+
+// WithRelativeToDir makes the encoder write the file URLs of tracks as paths
+// relative to the directory dir, usually that of the output playlist, where
+// possible. It reverses WithBaseDir.
+func WithRelativeToDir(dir string) EncoderOption {
+	return func(e *Encoder) {
+		e.relativeTo = dirURL(dir)
+	}
+}
+
+// WithFilePathOutput makes the encoder write the URLs of tracks that only hold
+// a path as is, as file paths, rather than in their escaped URL form. It
+// reverses WithFilePaths, and writes the paths set by Rebase.
+func WithFilePathOutput() EncoderOption {
+	return func(e *Encoder) {
+		e.filePaths = true
+	}
+}
+
+// WithLossless makes the encoder reproduce the attribute quoting style recorded
+// by the Decoder, instead of double-quoting every attribute value. Values that
+// can no longer be written in their recorded style are double-quoted.
+func WithLossless() EncoderOption {
+	return func(e *Encoder) {
+		e.lossless = true
+	}
 }
 
 // NewEncoder returns a new encoder that writes to w.
-func NewEncoder(w io.Writer) *Encoder {
-	return &Encoder{w: w}
+func NewEncoder(w io.Writer, opts ...EncoderOption) *Encoder {
+	e := &Encoder{w: bufio.NewWriter(w), lineEnding: LF, m3uDirectives: true}
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	return e
 }
 
 // Encode writes the M3U encoding of p to the stream.
 func (e *Encoder) Encode(playlist *Playlist, playlistType PlaylistType) error {
-	e.write("#EXTM3U")
-	e.writeURLAttr("url-tvg", playlist.TVGURL)
-	e.writeURLAttr("x-tvg-url", playlist.XTVGURL)
+	e.writeHeader(playlist)
 
-	// Write extra attributes in a deterministic (sorted) order
-	for _, key := range slices.Sorted(maps.Keys(playlist.ExtraAttributes)) {
-		e.write(fmt.Sprintf(" %s=\"%s\"", key, playlist.ExtraAttributes[key]))
+	for i := range playlist.Tracks {
+		e.writeTrack(&playlist.Tracks[i], playlistType)
 	}
 
-	e.write("\n")
+	return e.Flush()
+}
+
+// EncodeCompact writes the M3U encoding of c to the stream, materializing one
+// track at a time.
+func (e *Encoder) EncodeCompact(c *CompactPlaylist, playlistType PlaylistType) error {
+	e.writeHeader(&c.Header)
 
-	// Write tracks
-	for _, track := range playlist.Tracks {
-		e.write("#EXTINF:" + strconv.FormatFloat(track.Length, 'f', -1, 64))
+	for _, track := range c.All() {
+		e.writeTrack(&track, playlistType)
+	}
+
+	return e.Flush()
+}
 
-		if playlistType == M3UPlus {
-			e.writeAttr("tvg-id", track.TVGID)
-			e.writeAttr("tvg-name", track.TVGName)
-			e.writeAttr("tvg-language", track.TVGLanguage)
-			e.writeURLAttr("tvg-logo", track.TVGLogo)
-			e.writeAttr("group-title", track.GroupTitle)
+// EncodeHeader writes the #EXTM3U line of playlist to the stream, starting a
+// playlist whose tracks are written with EncodeTrack. The tracks of playlist
+// are not written.
+func (e *Encoder) EncodeHeader(playlist *Playlist) error {
+	e.writeHeader(playlist)
 
-			// Write extra attributes in a deterministic (sorted) order
-			for _, key := range slices.Sorted(maps.Keys(track.ExtraAttributes)) {
-				e.write(fmt.Sprintf(" %s=\"%s\"", key, track.ExtraAttributes[key]))
-			}
+	return e.err
+}
+
+// EncodeTrack writes the M3U encoding of track to the stream. It fails if no
+// header was written by EncodeHeader. The output is buffered until Flush is
+// called.
+func (e *Encoder) EncodeTrack(track *Track, playlistType PlaylistType) error {
+	if !e.headerWritten && e.err == nil {
+		e.err = errors.New("failed to encode track: header not written")
+	}
+
+	e.writeTrack(track, playlistType)
+
+	return e.err
+}
+
+// Flush writes any buffered output to the underlying writer. It must be called
+// after the last call to EncodeTrack.
+func (e *Encoder) Flush() error {
+	if e.err != nil {
+		return e.err
+	}
+
+	if err := e.w.Flush(); err != nil {
+		e.err = fmt.Errorf("failed to flush: %w", err)
+	}
+
+	return e.err
+}
+
+// writeHeader writes the #EXTM3U line of playlist.
+func (e *Encoder) writeHeader(playlist *Playlist) {
+	e.headerWritten = true
+	e.write("#EXTM3U")
+
+	attrs := e.attrs[:0]
+	attrs = appendURLAttr(attrs, "url-tvg", playlist.TVGURL, playlist.AttributeQuoting)
+	attrs = appendURLAttr(attrs, "x-tvg-url", playlist.XTVGURL, playlist.AttributeQuoting)
+	attrs = appendExtraAttrs(attrs, playlist.ExtraAttributes, playlist.AttributeQuoting)
+	e.writeAttrs(attrs, playlist.AttributeOrder)
+
+	e.write(string(e.lineEnding))
+
+	e.writeDirective("#PLAYLIST", playlist.Title)
+	e.writeMetadata(playlist.Album, playlist.Artist, playlist.Genre, playlist.Image)
+}
+
+// writeTrack writes the entry of track, followed by those of its alternate
+// URLs when flattening them.
+func (e *Encoder) writeTrack(track *Track, playlistType PlaylistType) {
+	e.writeEntry(track, track.URL, playlistType)
+
+	if e.alternateURLs != AlternateURLsFlatten {
+		return
+	}
+
+	for _, u := range track.AlternateURLs {
+		if u != nil {
+			e.writeEntry(track, u, playlistType)
 		}
+	}
+}
+
+// writeEntry writes the #EXTINF line and directives of track, followed by u.
+func (e *Encoder) writeEntry(track *Track, u *url.URL, playlistType PlaylistType) {
+	length := track.Length
+	if e.integerLengths {
+		length = integerLength(length)
+	}
 
-		e.write(fmt.Sprintf(",%s\n", track.Name))
+	e.write("#EXTINF:" + strconv.FormatFloat(length, 'f', -1, 64))
+
+	if playlistType == M3UPlus {
+		attrs := e.attrs[:0]
+		attrs = appendAttr(attrs, "tvg-id", track.TVGID, track.AttributeQuoting)
+		attrs = appendAttr(attrs, "tvg-name", track.TVGName, track.AttributeQuoting)
+		attrs = appendAttr(attrs, "tvg-language", track.TVGLanguage, track.AttributeQuoting)
+		attrs = appendURLAttr(attrs, "tvg-logo", track.TVGLogo, track.AttributeQuoting)
+		attrs = appendAttr(attrs, "group-title", track.GroupTitle, track.AttributeQuoting)
+		attrs = appendExtraAttrs(attrs, track.ExtraAttributes, track.AttributeQuoting)
+		e.writeAttrs(attrs, track.AttributeOrder)
+	}
+
+	e.write("," + track.Name + string(e.lineEnding))
+
+	// Write metadata and extra directives
+	if playlistType == M3UPlus || e.m3uDirectives {
+		e.writeMetadata(track.Album, track.Artist, track.Genre, track.Image)
 
-		// Write extra directives
 		for _, directive := range track.ExtraDirectives {
-			e.write(fmt.Sprintf("%s\n", directive))
+			e.write(directive + string(e.lineEnding))
 		}
+	}
 
-		// Write URL
-		if track.URL != nil {
-			e.write(fmt.Sprintf("%s\n", track.URL.String()))
-		}
+	// Write URL
+	if u != nil {
+		e.write(e.location(u) + string(e.lineEnding))
 	}
+}
 
-	return e.err
+// writeMetadata writes the #EXTALB, #EXTART, #EXTGENRE and #EXTIMG directives
+// of the fields that are set.
+func (e *Encoder) writeMetadata(album, artist, genre *string, image *url.URL) {
+	e.writeDirective("#EXTALB", album)
+	e.writeDirective("#EXTART", artist)
+	e.writeDirective("#EXTGENRE", genre)
+
+	if image == nil {
+		return
+	}
+
+	// Unlike track URLs, image paths are not read as directives when they
+	// start with '#'
+	location := e.location(image)
+	if e.filePaths && *image == (url.URL{Path: image.Path}) && strings.HasPrefix(image.Path, "#") {
+		location = image.Path
+	}
+
+	e.write("#EXTIMG:" + location + string(e.lineEnding))
+}
+
+// writeDirective writes the directive name with value, unless value is nil.
+func (e *Encoder) writeDirective(name string, value *string) {
+	if value != nil {
+		e.write(name + ":" + *value + string(e.lineEnding))
+	}
 }
 
 // Marshal returns the M3U encoding of p.
@@ -78,27 +378,167 @@ func Marshal(p *Playlist, playlistType PlaylistType) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-// writeAttr writes a quoted key="value" attribute when value is non-nil.
-func (e *Encoder) writeAttr(key string, value *string) {
-	if value != nil {
-		e.write(fmt.Sprintf(" %s=\"%s\"", key, *value))
+// encodedAttribute is an attribute about to be written by an Encoder.
+type encodedAttribute struct {
+	key     string
+	value   string
+	quoting Quoting
+}
+
+// appendAttr appends the attribute key to attrs when value is non-nil.
+func appendAttr(attrs []encodedAttribute, key string, value *string, quoting map[string]Quoting) []encodedAttribute {
+	if value == nil {
+		return attrs
 	}
+
+	return append(attrs, encodedAttribute{key: key, value: *value, quoting: quoting[key]})
 }
 
-// writeURLAttr writes a quoted key="url" attribute when u is non-nil.
-func (e *Encoder) writeURLAttr(key string, u *url.URL) {
-	if u != nil {
-		e.write(fmt.Sprintf(" %s=\"%s\"", key, u.String()))
+// appendURLAttr appends the attribute key to attrs when u is non-nil.
+func appendURLAttr(attrs []encodedAttribute, key string, u *url.URL, quoting map[string]Quoting) []encodedAttribute {
+	if u == nil {
+		return attrs
+	}
+
+	return append(attrs, encodedAttribute{key: key, value: urlString(u), quoting: quoting[key]})
+}
+
+// appendExtraAttrs appends the extra attributes to attrs in a deterministic
+// (sorted) order.
+func appendExtraAttrs(attrs []encodedAttribute, extra map[string]string, quoting map[string]Quoting) []encodedAttribute {
+	for _, key := range slices.Sorted(maps.Keys(extra)) {
+		attrs = append(attrs, encodedAttribute{key: key, value: extra[key], quoting: quoting[key]})
+	}
+
+	return attrs
+}
+
+// writeAttrs writes the selected attributes of attrs, which are in the fixed
+// order, in the order of the encoder. original is the recorded order of the
+// attributes.
+func (e *Encoder) writeAttrs(attrs []encodedAttribute, original []string) {
+	attrs = slices.DeleteFunc(attrs, func(attr encodedAttribute) bool {
+		return (e.included != nil && !e.included[attr.key]) || e.excluded[attr.key]
+	})
+
+	switch e.attributeOrder {
+	case AttributeOrderSorted:
+		slices.SortStableFunc(attrs, func(a, b encodedAttribute) int {
+			return strings.Compare(a.key, b.key)
+		})
+	case AttributeOrderOriginal:
+		sortAttrs(attrs, original)
+	}
+
+	sortAttrs(attrs, e.customOrder)
+
+	for _, attr := range attrs {
+		e.writeValueAttr(attr.key, attr.value, attr.quoting)
+	}
+
+	e.attrs = attrs
+}
+
+// sortAttrs moves the attributes named by keys to the front of attrs, in the
+// order of keys, keeping the order of the others.
+func sortAttrs(attrs []encodedAttribute, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+
+	rank := func(key string) int {
+		if i := slices.Index(keys, key); i >= 0 {
+			return i
+		}
+
+		return len(keys)
+	}
+
+	slices.SortStableFunc(attrs, func(a, b encodedAttribute) int {
+		return cmp.Compare(rank(a.key), rank(b.key))
+	})
+}
+
+// writeValueAttr writes a key="value" attribute. In lossless mode the value is
+// written with the given quoting style when possible.
+func (e *Encoder) writeValueAttr(key string, value string, quoting Quoting) {
+	if !e.lossless {
+		quoting = DoubleQuoted
+	}
+
+	switch quoting {
+	case SingleQuoted:
+		e.write(" " + key + "='" + escapeValue(value, '\'') + "'")
+	case Unquoted:
+		if value != "" && !strings.ContainsAny(value, " \t\n\f\r,\"'") {
+			e.write(" " + key + "=" + value)
+			break
+		}
+
+		fallthrough
+	default:
+		e.write(" " + key + "=\"" + escapeValue(value, '"') + "\"")
 	}
 }
 
+// urlString returns the text of u. The empty URL and URLs with only a fragment
+// are prefixed with "//", which parses back to them, since an empty line or
+// attribute value would not, and a line starting with '#' is a directive.
+func urlString(u *url.URL) string {
+	s := u.String()
+	if s != "" && s[0] != '#' {
+		return s
+	}
+
+	return "//" + s
+}
+
+// location returns the text of the track URL u, relative to the location set
+// with WithRelativeTo if possible.
+func (e *Encoder) location(u *url.URL) string {
+	if e.relativeTo != nil {
+		if rel, ok := RelativeLocation(u, e.relativeTo); ok {
+			return rel
+		}
+	}
+
+	if e.filePaths {
+		return locationString(u)
+	}
+
+	return urlString(u)
+}
+
+// escapeValue escapes the quote characters of a value quoted with quote, along
+// with the backslashes that would otherwise be read as escapes.
+func escapeValue(value string, quote byte) string {
+	if !strings.ContainsAny(value, string(quote)+`\`) {
+		return value
+	}
+
+	var b strings.Builder
+
+	for i := 0; i < len(value); i++ {
+		switch {
+		case value[i] == quote:
+			b.WriteByte('\\')
+		case value[i] == '\\' && (i+1 == len(value) || value[i+1] == quote || value[i+1] == '\\'):
+			b.WriteByte('\\')
+		}
+
+		b.WriteByte(value[i])
+	}
+
+	return b.String()
+}
+
 // write appends s to the stream, retaining the first error encountered.
 func (e *Encoder) write(s string) {
 	if e.err != nil {
 		return
 	}
 
-	if _, err := io.WriteString(e.w, s); err != nil {
+	if _, err := e.w.WriteString(s); err != nil {
 		e.err = fmt.Errorf("failed to write string: %w", err)
 	}
 }
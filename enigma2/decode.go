@@ -0,0 +1,180 @@
+package enigma2
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sherif-fanous/m3u"
+)
+
+var bouquetsEntryRegex = regexp.MustCompile(`FROM BOUQUET "([^"]+)"`)
+
+// Decoder reads bouquets from an input stream.
+type Decoder struct {
+	r          *bufio.Reader
+	lineNumber int
+}
+
+// NewDecoder returns a new decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// Decode reads a bouquet from its input. Services that are not streams, such
+// as DVB channels and references to other bouquets, are skipped. The
+// #DESCRIPTION line following a service overrides the name in its reference.
+func (d *Decoder) Decode(bouquet *Bouquet) error {
+	*bouquet = Bouquet{}
+
+	var (
+		group string
+		// described is the track or group name the next #DESCRIPTION line
+		// applies to, if any
+		described *string
+	)
+
+	for {
+		line, err := d.readLine()
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		switch {
+		case strings.HasPrefix(line, "#NAME "):
+			bouquet.Name = strings.TrimPrefix(line, "#NAME ")
+			described = nil
+		case strings.HasPrefix(line, "#SERVICE "):
+			track, marker, parseErr := d.parseService(line)
+			if parseErr != nil {
+				return parseErr
+			}
+
+			switch {
+			case marker != nil:
+				group = *marker
+				described = &group
+			case track != nil:
+				if group != "" {
+					title := group
+					track.GroupTitle = &title
+				}
+
+				bouquet.Tracks = append(bouquet.Tracks, *track)
+
+				described = &bouquet.Tracks[len(bouquet.Tracks)-1].Name
+			default:
+				described = nil
+			}
+		case strings.HasPrefix(line, "#DESCRIPTION "):
+			if described != nil {
+				*described = strings.TrimPrefix(line, "#DESCRIPTION ")
+
+				// The group title of the tracks is set as they are read, so
+				// only the marker's own description may rename the group
+				described = nil
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// Unmarshal parses the bouquet-encoded data and returns the bouquet.
+func Unmarshal(data []byte) (*Bouquet, error) {
+	bouquet := &Bouquet{}
+
+	if err := NewDecoder(bytes.NewReader(data)).Decode(bouquet); err != nil {
+		return nil, err
+	}
+
+	return bouquet, nil
+}
+
+// ParseBouquets returns the names of the userbouquet files referenced by a
+// bouquets.tv file, in order.
+func ParseBouquets(r io.Reader) ([]string, error) {
+	var filenames []string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "#SERVICE ") {
+			continue
+		}
+
+		if matches := bouquetsEntryRegex.FindStringSubmatch(line); matches != nil {
+			filenames = append(filenames, matches[1])
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading line: %w", err)
+	}
+
+	return filenames, nil
+}
+
+// parseService parses a #SERVICE line. It returns the track of a stream, the
+// label of a marker, or neither for other services.
+func (d *Decoder) parseService(line string) (*m3u.Track, *string, error) {
+	reference := strings.TrimPrefix(line, "#SERVICE ")
+
+	// The name is the last field and may itself contain colons
+	fields := strings.SplitN(reference, ":", 12)
+	if len(fields) < 10 {
+		return nil, nil, d.newError(line, "malformed `#SERVICE` line: service reference has fewer than 10 fields")
+	}
+
+	name := ""
+	if len(fields) == 12 {
+		name = fields[11]
+	}
+
+	flags, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil, nil, d.newError(line, fmt.Sprintf("malformed `#SERVICE` line: invalid service flags %q", fields[1]))
+	}
+
+	if flags&markerFlag != 0 {
+		return nil, &name, nil
+	}
+
+	if len(fields) < 11 || fields[10] == "" || strings.HasPrefix(fields[10], "FROM BOUQUET") {
+		return nil, nil, nil
+	}
+
+	rawURL, err := unescapeReference(fields[10])
+	if err != nil {
+		return nil, nil, d.newError(line, fmt.Sprintf("invalid URL: %v", err))
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, nil, d.newError(line, fmt.Sprintf("invalid URL: %v", err))
+	}
+
+	return &m3u.Track{Length: -1, Name: name, URL: u}, nil, nil
+}
+
+func (d *Decoder) newError(line, message string) error {
+	return InvalidBouquetError{Message: message, LineNumber: d.lineNumber, Line: line}
+}
+
+func (d *Decoder) readLine() (string, error) {
+	d.lineNumber++
+
+	line, err := d.r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("error reading line: %w", err)
+	}
+
+	return strings.TrimSpace(line), err
+}
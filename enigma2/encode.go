@@ -0,0 +1,98 @@
+package enigma2
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sherif-fanous/m3u"
+)
+
+// Encoder writes bouquets to an output stream.
+type Encoder struct {
+	w           io.Writer
+	err         error
+	serviceType int
+}
+
+// EncoderOption configures an Encoder.
+type EncoderOption func(*Encoder)
+
+// WithServiceType sets the service type of stream references, which selects
+// the media player of the receiver. The default is ServiceGStreamer.
+func WithServiceType(serviceType int) EncoderOption {
+	return func(e *Encoder) {
+		e.serviceType = serviceType
+	}
+}
+
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w io.Writer, opts ...EncoderOption) *Encoder {
+	e := &Encoder{w: w, serviceType: ServiceGStreamer}
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	return e
+}
+
+// Encode writes the tracks of playlist to the stream as a bouquet with the
+// given name. A marker labelled with the group title is written before each
+// run of tracks that share a group title. Tracks without a URL are skipped.
+//
+// Tracks without a group title that follow a marker cannot be told apart from
+// the tracks of the marker's group when the bouquet is decoded.
+func (e *Encoder) Encode(name string, playlist *m3u.Playlist) error {
+	e.write("#NAME " + singleLine(name) + "\n")
+
+	markers := 0
+	group := ""
+
+	for _, track := range playlist.Tracks {
+		if track.URL == nil {
+			continue
+		}
+
+		if track.GroupTitle != nil && *track.GroupTitle != group {
+			group = *track.GroupTitle
+			markers++
+
+			e.write(fmt.Sprintf("#SERVICE 1:%d:%d:0:0:0:0:0:0:0::%s\n", markerFlag, markers, singleLine(group)))
+			e.write("#DESCRIPTION " + singleLine(group) + "\n")
+		}
+
+		e.write(fmt.Sprintf("#SERVICE %d:0:1:0:0:0:0:0:0:0:%s:%s\n",
+			e.serviceType, escapeReference(track.URL.String()), singleLine(track.Name)))
+		e.write("#DESCRIPTION " + singleLine(track.Name) + "\n")
+	}
+
+	return e.err
+}
+
+// Marshal returns the bouquet encoding of playlist with the given name.
+func Marshal(name string, playlist *m3u.Playlist) ([]byte, error) {
+	var buf bytes.Buffer
+
+	if err := NewEncoder(&buf).Encode(name, playlist); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// singleLine replaces the line breaks of s with spaces.
+func singleLine(s string) string {
+	return strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ").Replace(s)
+}
+
+// write appends s to the stream, retaining the first error encountered.
+func (e *Encoder) write(s string) {
+	if e.err != nil {
+		return
+	}
+
+	if _, err := io.WriteString(e.w, s); err != nil {
+		e.err = fmt.Errorf("failed to write string: %w", err)
+	}
+}
@@ -0,0 +1,90 @@
+// Package enigma2 converts playlists to and from the bouquet files of Enigma2
+// set-top boxes.
+//
+// A bouquet is a userbouquet.*.tv file listing services, one per #SERVICE
+// line. Streams are services whose reference carries the stream URL, such as
+//
+//	#SERVICE 4097:0:1:0:0:0:0:0:0:0:http%3a//127.0.0.1/stream.ts:Channel 1
+//	#DESCRIPTION Channel 1
+//
+// Bouquets are listed in the bouquets.tv file, which references each of them
+// with a line returned by BouquetsEntry.
+package enigma2
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"github.com/sherif-fanous/m3u"
+)
+
+// Service types of stream references. ServiceGStreamer is understood by every
+// Enigma2 image; the others select alternative media players.
+const (
+	ServiceGStreamer   = 4097
+	ServiceExtEplayer3 = 5002
+)
+
+// markerFlag is the service flag of markers, which label the following
+// services of the bouquet.
+const markerFlag = 64
+
+// Bouquet is a named list of tracks.
+type Bouquet struct {
+	Name string
+	// Tracks holds a track for every stream service. The group title of a
+	// track is the label of the marker preceding it.
+	Tracks []m3u.Track
+}
+
+// InvalidBouquetError describes a line of a bouquet that cannot be parsed.
+type InvalidBouquetError struct {
+	Message    string
+	LineNumber int
+	Line       string
+}
+
+func (e InvalidBouquetError) Error() string {
+	return fmt.Sprintf("invalid enigma2 bouquet: line %d: `%s`: %s", e.LineNumber, e.Line, e.Message)
+}
+
+// Filename returns the name of the userbouquet file for a bouquet with the
+// given name, such as userbouquet.my_channels.tv.
+func Filename(name string) string {
+	var b strings.Builder
+
+	for _, r := range strings.ToLower(name) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+
+	if b.Len() == 0 {
+		b.WriteString("m3u")
+	}
+
+	return "userbouquet." + b.String() + ".tv"
+}
+
+// BouquetsEntry returns the line of the bouquets.tv file that references the
+// userbouquet file with the given name.
+func BouquetsEntry(filename string) string {
+	return fmt.Sprintf("#SERVICE 1:7:1:0:0:0:0:0:0:0:FROM BOUQUET \"%s\" ORDER BY bouquet", filename)
+}
+
+var referenceEscaper = strings.NewReplacer("%", "%25", ":", "%3a", "\n", "%0a", "\r", "%0d")
+
+// escapeReference escapes s for use as a field of a service reference, whose
+// fields are separated by colons.
+func escapeReference(s string) string {
+	return referenceEscaper.Replace(s)
+}
+
+// unescapeReference reverses escapeReference.
+func unescapeReference(s string) (string, error) {
+	return url.PathUnescape(s)
+}
@@ -0,0 +1,176 @@
+package enigma2_test
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sherif-fanous/m3u"
+	"github.com/sherif-fanous/m3u/enigma2"
+)
+
+func makePointer[T any](t T) *T {
+	return &t
+}
+
+func makeURL(t *testing.T, s string) *url.URL {
+	t.Helper()
+
+	u, err := url.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return u
+}
+
+const bouquet = `#NAME My Channels
+#SERVICE 1:64:1:0:0:0:0:0:0:0::News
+#DESCRIPTION News
+#SERVICE 4097:0:1:0:0:0:0:0:0:0:http%3a//127.0.0.1%3a8080/live/1.ts?a=b%25c:News 1
+#DESCRIPTION News 1
+#SERVICE 4097:0:1:0:0:0:0:0:0:0:http%3a//127.0.0.1/live/2.ts:News: Late
+#DESCRIPTION News: Late
+#SERVICE 1:64:2:0:0:0:0:0:0:0::Sports
+#DESCRIPTION Sports
+#SERVICE 4097:0:1:0:0:0:0:0:0:0:http%3a//127.0.0.1/live/3.ts:Sports 1
+#DESCRIPTION Sports 1
+`
+
+func makePlaylist(t *testing.T) *m3u.Playlist {
+	t.Helper()
+
+	return &m3u.Playlist{
+		Tracks: []m3u.Track{
+			{
+				Length:     -1,
+				Name:       "News 1",
+				GroupTitle: makePointer("News"),
+				URL:        makeURL(t, "http://127.0.0.1:8080/live/1.ts?a=b%c"),
+			},
+			{
+				Length:     -1,
+				Name:       "News: Late",
+				GroupTitle: makePointer("News"),
+				URL:        makeURL(t, "http://127.0.0.1/live/2.ts"),
+			},
+			{Length: -1, Name: "No URL", GroupTitle: makePointer("News")},
+			{
+				Length:     -1,
+				Name:       "Sports 1",
+				GroupTitle: makePointer("Sports"),
+				URL:        makeURL(t, "http://127.0.0.1/live/3.ts"),
+			},
+		},
+	}
+}
+
+func TestEncode(t *testing.T) {
+	t.Parallel()
+
+	data, err := enigma2.Marshal("My Channels", makePlaylist(t))
+	if err != nil {
+		t.Fatalf("Failed to marshal bouquet: %v", err)
+	}
+
+	if diff := cmp.Diff(string(data), bouquet); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestEncodeServiceType(t *testing.T) {
+	t.Parallel()
+
+	var b strings.Builder
+
+	playlist := &m3u.Playlist{Tracks: []m3u.Track{{Length: -1, Name: "Channel 1", URL: makeURL(t, "http://127.0.0.1/1.ts")}}}
+
+	if err := enigma2.NewEncoder(&b, enigma2.WithServiceType(enigma2.ServiceExtEplayer3)).Encode("Bouquet", playlist); err != nil {
+		t.Fatalf("Failed to encode bouquet: %v", err)
+	}
+
+	expected := "#NAME Bouquet\n" +
+		"#SERVICE 5002:0:1:0:0:0:0:0:0:0:http%3a//127.0.0.1/1.ts:Channel 1\n" +
+		"#DESCRIPTION Channel 1\n"
+
+	if diff := cmp.Diff(b.String(), expected); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	t.Parallel()
+
+	input := bouquet +
+		"#SERVICE 1:0:19:2B66:3F3:1:C00000:0:0:0:\n" +
+		"#DESCRIPTION DVB Channel\n" +
+		"#SERVICE 1:7:1:0:0:0:0:0:0:0:FROM BOUQUET \"userbouquet.other.tv\" ORDER BY bouquet\n" +
+		"#SERVICE 5001:0:1:0:0:0:0:0:0:0:http%3A//127.0.0.1/live/4.ts\n" +
+		"#DESCRIPTION Sports 2\n"
+
+	b, err := enigma2.Unmarshal([]byte(input))
+	if err != nil {
+		t.Fatalf("Failed to unmarshal bouquet: %v", err)
+	}
+
+	expectedPlaylist := makePlaylist(t)
+	expectedPlaylist.Tracks = append(
+		append(expectedPlaylist.Tracks[:2:2], expectedPlaylist.Tracks[3]),
+		m3u.Track{
+			Length:     -1,
+			Name:       "Sports 2",
+			GroupTitle: makePointer("Sports"),
+			URL:        makeURL(t, "http://127.0.0.1/live/4.ts"),
+		},
+	)
+
+	expectedBouquet := &enigma2.Bouquet{Name: "My Channels", Tracks: expectedPlaylist.Tracks}
+
+	if diff := cmp.Diff(b, expectedBouquet); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestDecodeInvalidService(t *testing.T) {
+	t.Parallel()
+
+	input := "#NAME Bouquet\n#SERVICE 4097:0:1\n"
+
+	_, err := enigma2.Unmarshal([]byte(input))
+
+	var invErr enigma2.InvalidBouquetError
+	if !errors.As(err, &invErr) {
+		t.Fatalf("Expected InvalidBouquetError, got: %v", err)
+	}
+
+	if invErr.LineNumber != 2 {
+		t.Errorf("Expected line 2, got %d", invErr.LineNumber)
+	}
+}
+
+func TestBouquetsEntry(t *testing.T) {
+	t.Parallel()
+
+	filename := enigma2.Filename("My Channels!")
+	if filename != "userbouquet.my_channels_.tv" {
+		t.Errorf("Unexpected file name %q", filename)
+	}
+
+	entry := enigma2.BouquetsEntry(filename)
+
+	expected := `#SERVICE 1:7:1:0:0:0:0:0:0:0:FROM BOUQUET "userbouquet.my_channels_.tv" ORDER BY bouquet`
+	if entry != expected {
+		t.Errorf("Expected %q, got %q", expected, entry)
+	}
+
+	filenames, err := enigma2.ParseBouquets(strings.NewReader("#NAME User - Bouquets (TV)\n" + entry + "\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse bouquets: %v", err)
+	}
+
+	if diff := cmp.Diff(filenames, []string{filename}); diff != "" {
+		t.Error(diff)
+	}
+}
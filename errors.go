@@ -1,13 +1,146 @@
 package m3u
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrorKind classifies the problem described by an InvalidPlaylistError.
+type ErrorKind int
+
+const (
+	// ErrorKindUnknown is the kind of errors that are not classified.
+	ErrorKindUnknown ErrorKind = iota
+	// ErrorKindMissingHeader marks playlists that do not start with #EXTM3U.
+	ErrorKindMissingHeader
+	// ErrorKindMalformedHeader marks #EXTM3U lines that cannot be parsed.
+	ErrorKindMalformedHeader
+	// ErrorKindMalformedEXTINF marks #EXTINF lines that cannot be parsed.
+	ErrorKindMalformedEXTINF
+	// ErrorKindOrphanDirective marks directives that precede any #EXTINF
+	// directive.
+	ErrorKindOrphanDirective
+	// ErrorKindMissingURL marks #EXTINF directive blocks that do not end with
+	// a URL.
+	ErrorKindMissingURL
+	// ErrorKindInvalidURL marks track URLs that cannot be parsed.
+	ErrorKindInvalidURL
+	// ErrorKindUnexpectedContent marks lines that are neither directives nor
+	// the URL of a track.
+	ErrorKindUnexpectedContent
+)
+
+// Sentinel errors matching the InvalidPlaylistError of each kind with
+// errors.Is.
+var (
+	ErrMissingHeader     = errors.New("m3u: missing #EXTM3U header")
+	ErrMalformedHeader   = errors.New("m3u: malformed #EXTM3U line")
+	ErrMalformedEXTINF   = errors.New("m3u: malformed #EXTINF line")
+	ErrOrphanDirective   = errors.New("m3u: directive before #EXTINF")
+	ErrMissingURL        = errors.New("m3u: #EXTINF directive block without a URL")
+	ErrInvalidURL        = errors.New("m3u: invalid track URL")
+	ErrUnexpectedContent = errors.New("m3u: unexpected content")
+)
+
+var errorKindSentinels = map[ErrorKind]error{
+	ErrorKindMissingHeader:     ErrMissingHeader,
+	ErrorKindMalformedHeader:   ErrMalformedHeader,
+	ErrorKindMalformedEXTINF:   ErrMalformedEXTINF,
+	ErrorKindOrphanDirective:   ErrOrphanDirective,
+	ErrorKindMissingURL:        ErrMissingURL,
+	ErrorKindInvalidURL:        ErrInvalidURL,
+	ErrorKindUnexpectedContent: ErrUnexpectedContent,
+}
+
+// String returns the lower-case name of the error kind.
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrorKindUnknown:
+		return "unknown"
+	case ErrorKindMissingHeader:
+		return "missing header"
+	case ErrorKindMalformedHeader:
+		return "malformed header"
+	case ErrorKindMalformedEXTINF:
+		return "malformed extinf"
+	case ErrorKindOrphanDirective:
+		return "orphan directive"
+	case ErrorKindMissingURL:
+		return "missing url"
+	case ErrorKindInvalidURL:
+		return "invalid url"
+	case ErrorKindUnexpectedContent:
+		return "unexpected content"
+	default:
+		return fmt.Sprintf("ErrorKind(%d)", int(k))
+	}
+}
 
 type InvalidPlaylistError struct {
 	Message    string
 	LineNumber int
 	Line       string
+	// Kind classifies the problem.
+	Kind ErrorKind
+	// Column is the 1-based byte offset in Line of the problem. Problems with
+	// a whole line are at column 1. With WithRedactedErrors, it is an offset
+	// in the line before it was redacted.
+	Column int
+	// Attribute is the name of the malformed attribute, if any.
+	Attribute string
 }
 
 func (e InvalidPlaylistError) Error() string {
 	return fmt.Sprintf("invalid m3u playlist: line %d: `%s`: %s", e.LineNumber, e.Line, e.Message)
 }
+
+// Is reports whether target is the sentinel error of the kind of e, such as
+// ErrMissingURL.
+func (e InvalidPlaylistError) Is(target error) bool {
+	sentinel, ok := errorKindSentinels[e.Kind]
+
+	return ok && target == sentinel
+}
+
+// LimitError is returned by a Decoder when the playlist exceeds one of the
+// limits set with WithLimits.
+type LimitError struct {
+	// Limit names the exceeded limit, such as "line length".
+	Limit string
+	// Max is the value of the limit.
+	Max        int64
+	LineNumber int
+}
+
+func (e LimitError) Error() string {
+	return fmt.Sprintf("m3u playlist limit exceeded: line %d: %s exceeds %d", e.LineNumber, e.Limit, e.Max)
+}
+
+// Warning describes a problem the Decoder recovered from without losing data.
+type Warning struct {
+	Message    string
+	LineNumber int
+	Line       string
+	// Attribute and Value are the name and raw value of the attribute the
+	// warning is about, if any.
+	Attribute string
+	Value     string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("m3u playlist warning: line %d: `%s`: %s", w.LineNumber, w.Line, w.Message)
+}
+
+// InvalidCSVError describes a cell of a CSV playlist that cannot be imported.
+type InvalidCSVError struct {
+	Message string
+	// Row is the line number of the record, counting the header as line 1.
+	Row int
+	// Column is the header of the column.
+	Column string
+	Value  string
+}
+
+func (e InvalidCSVError) Error() string {
+	return fmt.Sprintf("invalid csv playlist: row %d: column %s: `%s`: %s", e.Row, e.Column, e.Value, e.Message)
+}
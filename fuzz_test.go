@@ -0,0 +1,151 @@
+package m3u_test
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sherif-fanous/m3u"
+)
+
+// compareUserinfo compares the user information of URLs, whose fields are
+// unexported.
+var compareUserinfo = cmp.Comparer(func(a, b *url.Userinfo) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.String() == b.String()
+})
+
+// addFixtureSeeds adds the playlists used by the tests in m3u_test.go to the
+// seed corpus of f, so that new fixtures seed the fuzz tests too.
+func addFixtureSeeds(f *testing.F) {
+	f.Helper()
+
+	file, err := parser.ParseFile(token.NewFileSet(), "m3u_test.go", nil, 0)
+	if err != nil {
+		f.Fatalf("Failed to parse fixtures: %v", err)
+	}
+
+	seeds := 0
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		lit, ok := n.(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			return true
+		}
+
+		s, err := strconv.Unquote(lit.Value)
+		if err != nil || !strings.Contains(s, "#EXT") {
+			return true
+		}
+
+		f.Add([]byte(s))
+		seeds++
+
+		return true
+	})
+
+	if seeds == 0 {
+		f.Fatal("Found no fixtures to seed the corpus with")
+	}
+}
+
+func FuzzUnmarshal(f *testing.F) {
+	addFixtureSeeds(f)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		playlist, err := m3u.Unmarshal(data)
+		if err != nil {
+			return
+		}
+
+		// Anything the decoder accepts must encode in both formats
+		for _, playlistType := range []m3u.PlaylistType{m3u.M3U, m3u.M3UPlus} {
+			if _, err := m3u.Marshal(playlist, playlistType); err != nil {
+				t.Fatalf("Failed to marshal decoded playlist: %v", err)
+			}
+		}
+	})
+}
+
+func FuzzRoundTrip(f *testing.F) {
+	addFixtureSeeds(f)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		playlist, err := m3u.Unmarshal(data)
+		if err != nil {
+			return
+		}
+
+		// Lossless encoding must reproduce the playlist exactly
+		var lossless bytes.Buffer
+		if err := m3u.NewEncoder(&lossless, m3u.WithLossless()).Encode(playlist, m3u.M3UPlus); err != nil {
+			t.Fatalf("Failed to encode: %v", err)
+		}
+
+		decoded, err := m3u.Unmarshal(lossless.Bytes())
+		if err != nil {
+			t.Fatalf("Failed to unmarshal lossless encoding %q: %v", lossless.String(), err)
+		}
+
+		if diff := cmp.Diff(decoded, playlist, compareUserinfo); diff != "" {
+			t.Fatalf("Lossless round trip of %q changed the playlist:\n%s", lossless.String(), diff)
+		}
+
+		// Flattening the fallbacks grouped by the decoder must restore the
+		// entries they were grouped from
+		var grouped m3u.Playlist
+		if err := m3u.NewDecoder(bytes.NewReader(data), m3u.WithFallbackGrouping()).Decode(&grouped); err != nil {
+			t.Fatalf("Failed to decode with fallback grouping: %v", err)
+		}
+
+		var flattened bytes.Buffer
+		if err := m3u.NewEncoder(&flattened, m3u.WithLossless()).Encode(&grouped, m3u.M3UPlus); err != nil {
+			t.Fatalf("Failed to encode grouped playlist: %v", err)
+		}
+
+		decoded, err = m3u.Unmarshal(flattened.Bytes())
+		if err != nil {
+			t.Fatalf("Failed to unmarshal flattened encoding %q: %v", flattened.String(), err)
+		}
+
+		if diff := cmp.Diff(decoded, playlist, compareUserinfo); diff != "" {
+			t.Fatalf("Flattening %q changed the playlist:\n%s", flattened.String(), diff)
+		}
+
+		// Default encoding double-quotes every attribute, so only the
+		// quoting styles may change
+		data, err = m3u.Marshal(playlist, m3u.M3UPlus)
+		if err != nil {
+			t.Fatalf("Failed to marshal: %v", err)
+		}
+
+		decoded, err = m3u.Unmarshal(data)
+		if err != nil {
+			t.Fatalf("Failed to unmarshal encoding %q: %v", data, err)
+		}
+
+		clearQuoting(playlist)
+
+		if diff := cmp.Diff(decoded, playlist, compareUserinfo); diff != "" {
+			t.Fatalf("Round trip of %q changed the playlist:\n%s", data, diff)
+		}
+	})
+}
+
+// clearQuoting removes the recorded quoting styles of playlist.
+func clearQuoting(playlist *m3u.Playlist) {
+	playlist.AttributeQuoting = nil
+
+	for i := range playlist.Tracks {
+		playlist.Tracks[i].AttributeQuoting = nil
+	}
+}
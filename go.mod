@@ -3,3 +3,5 @@ module github.com/sherif-fanous/m3u
 go 1.24.1
 
 require github.com/google/go-cmp v0.7.0
+
+require gopkg.in/yaml.v3 v3.0.1
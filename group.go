@@ -0,0 +1,127 @@
+package m3u
+
+import (
+	"maps"
+	"slices"
+)
+
+// Group summarizes the tracks of a playlist that share a group title.
+type Group struct {
+	// Title is the group title. Tracks without a group title belong to the
+	// group with an empty title.
+	Title string
+	// Count is the number of tracks in the group.
+	Count int
+}
+
+// Groups returns the groups of the playlist in order of first appearance.
+func (p *Playlist) Groups() []Group {
+	var groups []Group
+
+	index := make(map[string]int)
+
+	for _, track := range p.Tracks {
+		title := groupTitle(track)
+
+		i, ok := index[title]
+		if !ok {
+			i = len(groups)
+			index[title] = i
+			groups = append(groups, Group{Title: title})
+		}
+
+		groups[i].Count++
+	}
+
+	return groups
+}
+
+// RenameGroups renames groups according to mapping, which maps current group
+// titles to new ones. Mapping several titles to the same new title merges
+// those groups, and mapping a title to the empty string removes the group
+// title from its tracks. It returns the number of tracks that were changed.
+func (p *Playlist) RenameGroups(mapping map[string]string) int {
+	changed := 0
+
+	for i := range p.Tracks {
+		track := &p.Tracks[i]
+
+		newTitle, ok := mapping[groupTitle(*track)]
+		if !ok || newTitle == groupTitle(*track) {
+			continue
+		}
+
+		if newTitle == "" {
+			track.GroupTitle = nil
+		} else {
+			track.GroupTitle = &newTitle
+		}
+
+		changed++
+	}
+
+	return changed
+}
+
+// ReorderGroups reorders the tracks of the playlist so that the groups listed
+// in order come first, in the given order. Groups that are not listed follow in
+// their original order, and tracks keep their relative order within a group.
+func (p *Playlist) ReorderGroups(order []string) {
+	rank := make(map[string]int, len(order))
+	for i, title := range order {
+		if _, ok := rank[title]; !ok {
+			rank[title] = i
+		}
+	}
+
+	// Unlisted groups are ranked after the listed ones by first appearance
+	for _, group := range p.Groups() {
+		if _, ok := rank[group.Title]; !ok {
+			rank[group.Title] = len(rank) + len(order)
+		}
+	}
+
+	slices.SortStableFunc(p.Tracks, func(a, b Track) int {
+		return rank[groupTitle(a)] - rank[groupTitle(b)]
+	})
+}
+
+// SplitByGroup splits the playlist into one playlist per group, keyed by group
+// title. Each playlist carries a copy of the header attributes of p. Use
+// Groups to iterate over the result in playlist order.
+func (p *Playlist) SplitByGroup() map[string]*Playlist {
+	playlists := make(map[string]*Playlist)
+
+	for _, track := range p.Tracks {
+		title := groupTitle(track)
+
+		playlist, ok := playlists[title]
+		if !ok {
+			playlist = &Playlist{
+				TVGURL:          p.TVGURL,
+				XTVGURL:         p.XTVGURL,
+				Title:           p.Title,
+				Album:           p.Album,
+				Artist:          p.Artist,
+				Genre:           p.Genre,
+				Image:           p.Image,
+				ExtraAttributes: maps.Clone(p.ExtraAttributes),
+			}
+			playlists[title] = playlist
+		}
+
+		playlist.Tracks = append(playlist.Tracks, track)
+	}
+
+	return playlists
+}
+
+// groupTitle returns the group title of track, or the empty string if it has
+// none.
+func groupTitle(track Track) string {
+	if track.GroupTitle == nil {
+		return ""
+	}
+
+	return *track.GroupTitle
+}
@@ -0,0 +1,110 @@
+package m3u_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sherif-fanous/m3u"
+)
+
+func makeGroupedPlaylist(t *testing.T) *m3u.Playlist {
+	t.Helper()
+
+	return &m3u.Playlist{
+		TVGURL: makeURL(t, "http://127.0.0.1/epg.xml"),
+		Tracks: []m3u.Track{
+			{Length: -1, Name: "News 1", GroupTitle: makePointer("News")},
+			{Length: -1, Name: "Sports 1", GroupTitle: makePointer("Sports")},
+			{Length: -1, Name: "Other 1"},
+			{Length: -1, Name: "News 2", GroupTitle: makePointer("News")},
+			{Length: -1, Name: "Sport 1", GroupTitle: makePointer("Sport")},
+		},
+	}
+}
+
+func TestGroups(t *testing.T) {
+	t.Parallel()
+
+	playlist := makeGroupedPlaylist(t)
+
+	expectedGroups := []m3u.Group{
+		{Title: "News", Count: 2},
+		{Title: "Sports", Count: 1},
+		{Title: "", Count: 1},
+		{Title: "Sport", Count: 1},
+	}
+
+	if diff := cmp.Diff(playlist.Groups(), expectedGroups); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestRenameGroups(t *testing.T) {
+	t.Parallel()
+
+	playlist := makeGroupedPlaylist(t)
+
+	changed := playlist.RenameGroups(map[string]string{
+		"Sport": "Sports",
+		"":      "Misc",
+		"News":  "",
+	})
+	if changed != 4 {
+		t.Errorf("Expected 4 changed tracks, got %d", changed)
+	}
+
+	expectedGroups := []m3u.Group{
+		{Title: "", Count: 2},
+		{Title: "Sports", Count: 2},
+		{Title: "Misc", Count: 1},
+	}
+
+	if diff := cmp.Diff(playlist.Groups(), expectedGroups); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestReorderGroups(t *testing.T) {
+	t.Parallel()
+
+	playlist := makeGroupedPlaylist(t)
+	playlist.ReorderGroups([]string{"Sport", "News"})
+
+	var names []string
+	for _, track := range playlist.Tracks {
+		names = append(names, track.Name)
+	}
+
+	expectedNames := []string{"Sport 1", "News 1", "News 2", "Sports 1", "Other 1"}
+
+	if diff := cmp.Diff(names, expectedNames); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestSplitByGroup(t *testing.T) {
+	t.Parallel()
+
+	playlist := makeGroupedPlaylist(t)
+	playlists := playlist.SplitByGroup()
+
+	if len(playlists) != 4 {
+		t.Fatalf("Expected 4 playlists, got %d", len(playlists))
+	}
+
+	expectedNews := &m3u.Playlist{
+		TVGURL: makeURL(t, "http://127.0.0.1/epg.xml"),
+		Tracks: []m3u.Track{
+			{Length: -1, Name: "News 1", GroupTitle: makePointer("News")},
+			{Length: -1, Name: "News 2", GroupTitle: makePointer("News")},
+		},
+	}
+
+	if diff := cmp.Diff(playlists["News"], expectedNews); diff != "" {
+		t.Error(diff)
+	}
+
+	if got := len(playlists[""].Tracks); got != 1 {
+		t.Errorf("Expected 1 ungrouped track, got %d", got)
+	}
+}
@@ -0,0 +1,226 @@
+// Package hdhomerun emulates an HDHomeRun network tuner on top of an M3U
+// playlist, so that DVR software that only ingests HDHomeRun tuners, such as
+// Plex and Jellyfin, can use the playlist's channels.
+package hdhomerun
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/sherif-fanous/m3u"
+)
+
+// Device describes the emulated tuner. Empty fields take the defaults of
+// DefaultDevice.
+type Device struct {
+	FriendlyName    string
+	Manufacturer    string
+	ModelNumber     string
+	FirmwareName    string
+	FirmwareVersion string
+	// DeviceID is the 8 hexadecimal digit identifier of the tuner.
+	DeviceID   string
+	DeviceAuth string
+	TunerCount int
+	// BaseURL is the address the handler is reachable at. If empty, it is
+	// derived from the Host header of each request.
+	BaseURL string
+}
+
+// DefaultDevice holds the defaults for empty Device fields.
+var DefaultDevice = Device{
+	FriendlyName:    "M3U HDHomeRun",
+	Manufacturer:    "Silicondust",
+	ModelNumber:     "HDTC-2US",
+	FirmwareName:    "hdhomeruntc_atsc",
+	FirmwareVersion: "20200101",
+	DeviceID:        "12345678",
+	DeviceAuth:      "m3u",
+	TunerCount:      2,
+}
+
+// Channel is an entry of the tuner lineup.
+type Channel struct {
+	GuideNumber string `json:"GuideNumber"`
+	GuideName   string `json:"GuideName"`
+	URL         string `json:"URL"`
+}
+
+// Handler serves the discover.json, lineup.json, lineup_status.json and
+// device.xml endpoints of an HDHomeRun tuner.
+type Handler struct {
+	device  Device
+	lineup  []Channel
+	baseURL *url.URL
+}
+
+// NewHandler returns a handler exposing the tracks of playlist as tuner
+// channels. The tvg-chno attribute of a track is used as its guide number,
+// falling back to its position in the playlist. Tracks without a URL are
+// skipped. The playlist is read once; later changes are not reflected.
+func NewHandler(playlist *m3u.Playlist, device Device) (*Handler, error) {
+	h := &Handler{device: withDefaults(device), lineup: []Channel{}}
+
+	if h.device.BaseURL != "" {
+		u, err := url.Parse(h.device.BaseURL)
+		if err != nil {
+			return nil, err
+		}
+
+		h.baseURL = u
+	}
+
+	for i, track := range playlist.Tracks {
+		if track.URL == nil {
+			continue
+		}
+
+		number := track.ExtraAttributes["tvg-chno"]
+		if number == "" {
+			number = strconv.Itoa(i + 1)
+		}
+
+		h.lineup = append(h.lineup, Channel{
+			GuideNumber: number,
+			GuideName:   track.Name,
+			URL:         track.URL.String(),
+		})
+	}
+
+	return h, nil
+}
+
+// Lineup returns the channels of the tuner.
+func (h *Handler) Lineup() []Channel {
+	return h.lineup
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/discover.json":
+		h.serveDiscover(w, r)
+	case "/lineup.json":
+		writeJSON(w, h.lineup)
+	case "/lineup_status.json":
+		writeJSON(w, map[string]any{
+			"ScanInProgress": 0,
+			"ScanPossible":   1,
+			"Source":         "Cable",
+			"SourceList":     []string{"Cable"},
+		})
+	case "/lineup.post":
+		// Channel scans complete immediately as the lineup is fixed
+		w.WriteHeader(http.StatusOK)
+	case "/", "/device.xml":
+		h.serveDeviceXML(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *Handler) serveDiscover(w http.ResponseWriter, r *http.Request) {
+	base := h.base(r)
+
+	writeJSON(w, map[string]any{
+		"FriendlyName":    h.device.FriendlyName,
+		"Manufacturer":    h.device.Manufacturer,
+		"ModelNumber":     h.device.ModelNumber,
+		"FirmwareName":    h.device.FirmwareName,
+		"FirmwareVersion": h.device.FirmwareVersion,
+		"DeviceID":        h.device.DeviceID,
+		"DeviceAuth":      h.device.DeviceAuth,
+		"TunerCount":      h.device.TunerCount,
+		"BaseURL":         base.String(),
+		"LineupURL":       base.JoinPath("lineup.json").String(),
+	})
+}
+
+// deviceDescription is a UPnP root device description.
+type deviceDescription struct {
+	XMLName     xml.Name `xml:"urn:schemas-upnp-org:device-1-0 root"`
+	SpecVersion struct {
+		Major int `xml:"major"`
+		Minor int `xml:"minor"`
+	} `xml:"specVersion"`
+	URLBase string `xml:"URLBase"`
+	Device  struct {
+		DeviceType   string `xml:"deviceType"`
+		FriendlyName string `xml:"friendlyName"`
+		Manufacturer string `xml:"manufacturer"`
+		ModelName    string `xml:"modelName"`
+		ModelNumber  string `xml:"modelNumber"`
+		SerialNumber string `xml:"serialNumber"`
+		UDN          string `xml:"UDN"`
+	} `xml:"device"`
+}
+
+func (h *Handler) serveDeviceXML(w http.ResponseWriter, r *http.Request) {
+	var desc deviceDescription
+
+	desc.SpecVersion.Major = 1
+	desc.URLBase = h.base(r).String()
+	desc.Device.DeviceType = deviceType
+	desc.Device.FriendlyName = h.device.FriendlyName
+	desc.Device.Manufacturer = h.device.Manufacturer
+	desc.Device.ModelName = h.device.ModelNumber
+	desc.Device.ModelNumber = h.device.ModelNumber
+	desc.Device.SerialNumber = h.device.DeviceID
+	desc.Device.UDN = h.udn()
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write([]byte(xml.Header))
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	encoder.Encode(desc)
+}
+
+// base returns the address the handler is reachable at.
+func (h *Handler) base(r *http.Request) *url.URL {
+	if h.baseURL != nil {
+		return h.baseURL
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	return &url.URL{Scheme: scheme, Host: r.Host}
+}
+
+// udn returns the unique device name of the tuner.
+func (h *Handler) udn() string {
+	return "uuid:" + h.device.DeviceID
+}
+
+func withDefaults(device Device) Device {
+	set := func(field *string, fallback string) {
+		if *field == "" {
+			*field = fallback
+		}
+	}
+
+	set(&device.FriendlyName, DefaultDevice.FriendlyName)
+	set(&device.Manufacturer, DefaultDevice.Manufacturer)
+	set(&device.ModelNumber, DefaultDevice.ModelNumber)
+	set(&device.FirmwareName, DefaultDevice.FirmwareName)
+	set(&device.FirmwareVersion, DefaultDevice.FirmwareVersion)
+	set(&device.DeviceID, DefaultDevice.DeviceID)
+	set(&device.DeviceAuth, DefaultDevice.DeviceAuth)
+
+	if device.TunerCount == 0 {
+		device.TunerCount = DefaultDevice.TunerCount
+	}
+
+	return device
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,167 @@
+package hdhomerun_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sherif-fanous/m3u"
+	"github.com/sherif-fanous/m3u/hdhomerun"
+)
+
+func makeURL(t *testing.T, s string) *url.URL {
+	t.Helper()
+
+	u, err := url.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return u
+}
+
+func newHandler(t *testing.T) *hdhomerun.Handler {
+	t.Helper()
+
+	playlist := &m3u.Playlist{
+		Tracks: []m3u.Track{
+			{
+				Length:          -1,
+				Name:            "Channel 1",
+				URL:             makeURL(t, "http://127.0.0.1/stream_1"),
+				ExtraAttributes: map[string]string{"tvg-chno": "101"},
+			},
+			{
+				Length: -1,
+				Name:   "Channel 2",
+				URL:    makeURL(t, "http://127.0.0.1/stream_2"),
+			},
+		},
+	}
+
+	handler, err := hdhomerun.NewHandler(playlist, hdhomerun.Device{DeviceID: "ABCDEF01"})
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+
+	return handler
+}
+
+func getJSON(t *testing.T, server *httptest.Server, path string, v any) {
+	t.Helper()
+
+	resp, err := http.Get(server.URL + path)
+	if err != nil {
+		t.Fatalf("Failed to get %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("Failed to decode %s: %v", path, err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(newHandler(t))
+	t.Cleanup(server.Close)
+
+	var lineup []hdhomerun.Channel
+	getJSON(t, server, "/lineup.json", &lineup)
+
+	expectedLineup := []hdhomerun.Channel{
+		{GuideNumber: "101", GuideName: "Channel 1", URL: "http://127.0.0.1/stream_1"},
+		{GuideNumber: "2", GuideName: "Channel 2", URL: "http://127.0.0.1/stream_2"},
+	}
+
+	if diff := cmp.Diff(lineup, expectedLineup); diff != "" {
+		t.Error(diff)
+	}
+
+	var discover map[string]any
+	getJSON(t, server, "/discover.json", &discover)
+
+	if discover["DeviceID"] != "ABCDEF01" {
+		t.Errorf("Expected DeviceID ABCDEF01, got %v", discover["DeviceID"])
+	}
+
+	if discover["LineupURL"] != server.URL+"/lineup.json" {
+		t.Errorf("Expected LineupURL %s/lineup.json, got %v", server.URL, discover["LineupURL"])
+	}
+
+	var status map[string]any
+	getJSON(t, server, "/lineup_status.json", &status)
+
+	if status["ScanInProgress"] != float64(0) {
+		t.Errorf("Expected no scan in progress, got %v", status["ScanInProgress"])
+	}
+}
+
+func TestServeSSDP(t *testing.T) {
+	t.Parallel()
+
+	handler := newHandler(t)
+
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+
+	go func() {
+		done <- handler.ServeSSDP(ctx, conn, "http://127.0.0.1:5004/device.xml")
+	}()
+
+	client, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer client.Close()
+
+	search := "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: 239.255.255.250:1900\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: 1\r\n" +
+		"ST: urn:schemas-upnp-org:device:MediaServer:1\r\n\r\n"
+
+	if _, err := client.WriteTo([]byte(search), conn.LocalAddr()); err != nil {
+		t.Fatalf("Failed to send search: %v", err)
+	}
+
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	buf := make([]byte, 2048)
+
+	n, _, err := client.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+
+	response := string(buf[:n])
+	for _, expected := range []string{
+		"HTTP/1.1 200 OK\r\n",
+		"LOCATION: http://127.0.0.1:5004/device.xml\r\n",
+		"ST: urn:schemas-upnp-org:device:MediaServer:1\r\n",
+		"USN: uuid:ABCDEF01::urn:schemas-upnp-org:device:MediaServer:1\r\n",
+	} {
+		if !strings.Contains(response, expected) {
+			t.Errorf("Expected response to contain %q, got:\n%s", expected, response)
+		}
+	}
+
+	cancel()
+
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package hdhomerun
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// deviceType is the UPnP device type HDHomeRun tuners advertise.
+const deviceType = "urn:schemas-upnp-org:device:MediaServer:1"
+
+// SSDPAddr is the multicast address SSDP searches are sent to.
+var SSDPAddr = &net.UDPAddr{IP: net.IPv4(239, 255, 255, 250), Port: 1900}
+
+// ServeSSDP answers SSDP M-SEARCH requests received on conn until ctx is
+// canceled, advertising the device description at location, for example
+// http://192.168.1.10:5004/device.xml. Searches for ssdp:all,
+// upnp:rootdevice, the MediaServer device type or the device's UUID are
+// answered.
+//
+// To receive searches, conn is usually created with
+// net.ListenMulticastUDP("udp4", nil, SSDPAddr).
+func (h *Handler) ServeSSDP(ctx context.Context, conn net.PacketConn, location string) error {
+	stop := context.AfterFunc(ctx, func() {
+		conn.Close()
+	})
+	defer stop()
+
+	buf := make([]byte, 2048)
+
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			return err
+		}
+
+		st, ok := parseMSearch(buf[:n])
+		if !ok {
+			continue
+		}
+
+		for _, target := range h.searchTargets(st) {
+			if _, err := conn.WriteTo(h.ssdpResponse(target, location), addr); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// searchTargets returns the targets to answer a search for st with.
+func (h *Handler) searchTargets(st string) []string {
+	switch st {
+	case "ssdp:all":
+		return []string{"upnp:rootdevice", h.udn(), deviceType}
+	case "upnp:rootdevice", h.udn(), deviceType:
+		return []string{st}
+	default:
+		return nil
+	}
+}
+
+// ssdpResponse returns the unicast response to a search for target.
+func (h *Handler) ssdpResponse(target, location string) []byte {
+	usn := h.udn()
+	if target != usn {
+		usn += "::" + target
+	}
+
+	var b bytes.Buffer
+
+	b.WriteString("HTTP/1.1 200 OK\r\n")
+	fmt.Fprintf(&b, "CACHE-CONTROL: max-age=1800\r\n")
+	fmt.Fprintf(&b, "EXT:\r\n")
+	fmt.Fprintf(&b, "LOCATION: %s\r\n", location)
+	fmt.Fprintf(&b, "SERVER: %s/%s UPnP/1.0\r\n", h.device.FirmwareName, h.device.FirmwareVersion)
+	fmt.Fprintf(&b, "ST: %s\r\n", target)
+	fmt.Fprintf(&b, "USN: %s\r\n", usn)
+	b.WriteString("\r\n")
+
+	return b.Bytes()
+}
+
+// parseMSearch returns the search target of an SSDP M-SEARCH request.
+func parseMSearch(data []byte) (string, bool) {
+	req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(data)))
+	if err != nil || req.Method != "M-SEARCH" {
+		return "", false
+	}
+
+	if !strings.EqualFold(strings.Trim(req.Header.Get("Man"), `"`), "ssdp:discover") {
+		return "", false
+	}
+
+	return req.Header.Get("St"), true
+}
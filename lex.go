@@ -0,0 +1,269 @@
+package m3u
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Grammar of the #EXTM3U and #EXTINF lines, in regular expression syntax.
+// The lexer below scans lines by hand, as matching these expressions dominated
+// the time spent decoding large playlists; they are kept to document the
+// accepted grammar and to describe malformed lines in errors.
+//
+// Attribute values are double-quoted, single-quoted or unquoted. Quoted values
+// may contain the quote character escaped with a backslash.
+const (
+	extm3uLineGrammar = `^#EXTM3U(?:\s+(.*))?$`
+	extinfLineGrammar = `^#EXTINF:(-?\d+\.?\d*)((?:\s+[\p{L}\p{N}_-]+=(?:"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'|[^\s,"']+))*)\s*,(.*)$`
+)
+
+// mismatch locates the part of a line that does not match its grammar.
+type mismatch struct {
+	// offset is the byte offset of the first character that does not match
+	offset int
+	// attribute is the key of the attribute that does not match, if any
+	attribute string
+}
+
+// attribute is a key=value attribute scanned from a directive line.
+type attribute struct {
+	key     string
+	value   string
+	quoting Quoting
+}
+
+// lexEXTM3U scans the attributes of an #EXTM3U line into attrs. Text between
+// attributes that is not itself an attribute is skipped. It reports whether
+// the line matches extm3uLineGrammar, locating the mismatch if it does not.
+// When limit is positive, scanning stops once attrs holds more than limit
+// attributes, and the rest of the line is not checked.
+func lexEXTM3U(line string, attrs []attribute, limit int) ([]attribute, *mismatch) {
+	rest, ok := strings.CutPrefix(line, "#EXTM3U")
+	if !ok {
+		return attrs, &mismatch{}
+	}
+
+	if rest != "" && !isSpace(rest[0]) {
+		return attrs, &mismatch{offset: len(line) - len(rest)}
+	}
+
+	for i := 0; i < len(rest); {
+		keyEnd := lexKey(rest, i)
+		if keyEnd == i {
+			i++
+			continue
+		}
+
+		// Scanning again from inside the key would meet the same '=' and
+		// value, so a failed attribute is skipped as a whole, which keeps
+		// the scan linear
+		attr, end, ok := lexAttribute(rest, i)
+		if !ok {
+			i = keyEnd
+			continue
+		}
+
+		attrs = appendAttribute(attrs, attr)
+		if limit > 0 && len(attrs) > limit {
+			return attrs, nil
+		}
+
+		i = end
+	}
+
+	return attrs, nil
+}
+
+// lexEXTINF scans an #EXTINF line, appending its attributes to attrs. It
+// returns the text of the length and the name, and reports whether the line
+// matches extinfLineGrammar, locating the mismatch if it does not. When limit
+// is positive, scanning stops once attrs holds more than limit attributes, and
+// the rest of the line, name included, is not checked.
+func lexEXTINF(line string, attrs []attribute, limit int) (length, name string, _ []attribute, _ *mismatch) {
+	rest, ok := strings.CutPrefix(line, "#EXTINF:")
+	if !ok {
+		return "", "", attrs, &mismatch{}
+	}
+
+	// Offsets below are relative to rest
+	offset := len(line) - len(rest)
+
+	// Length
+	i := 0
+	if i < len(rest) && rest[i] == '-' {
+		i++
+	}
+
+	digits := i
+	for i < len(rest) && isDigit(rest[i]) {
+		i++
+	}
+
+	if i == digits {
+		return "", "", attrs, &mismatch{offset: offset + i}
+	}
+
+	if i < len(rest) && rest[i] == '.' {
+		i++
+		for i < len(rest) && isDigit(rest[i]) {
+			i++
+		}
+	}
+
+	length = rest[:i]
+
+	// Attributes, each preceded by whitespace
+	for {
+		j := i
+		for j < len(rest) && isSpace(rest[j]) {
+			j++
+		}
+
+		if j == i {
+			break
+		}
+
+		attr, end, ok := lexAttribute(rest, j)
+		if !ok {
+			break
+		}
+
+		attrs = appendAttribute(attrs, attr)
+		if limit > 0 && len(attrs) > limit {
+			return length, "", attrs, nil
+		}
+
+		i = end
+	}
+
+	// Name, after the comma
+	for i < len(rest) && isSpace(rest[i]) {
+		i++
+	}
+
+	if i == len(rest) || rest[i] != ',' {
+		m := &mismatch{offset: offset + i}
+
+		// Text that starts like an attribute is one that is malformed, such
+		// as one with an unterminated quoted value
+		if keyEnd := lexKey(rest, i); keyEnd != i && keyEnd < len(rest) && rest[keyEnd] == '=' {
+			m.attribute = rest[i:keyEnd]
+		}
+
+		return "", "", attrs, m
+	}
+
+	return length, strings.TrimSpace(rest[i+1:]), attrs, nil
+}
+
+// lexAttribute scans the attribute starting at s[i]. It returns the attribute
+// and the index following it, and reports whether there is one.
+func lexAttribute(s string, i int) (attribute, int, bool) {
+	keyEnd := lexKey(s, i)
+	if keyEnd == i || keyEnd+1 >= len(s) || s[keyEnd] != '=' {
+		return attribute{}, i, false
+	}
+
+	key := s[i:keyEnd]
+	start := keyEnd + 1
+
+	switch quote := s[start]; quote {
+	case '"', '\'':
+		escaped := false
+
+		for j := start + 1; j < len(s); j++ {
+			switch s[j] {
+			case '\\':
+				// A backslash escapes the following character, which must
+				// exist
+				if j+1 == len(s) {
+					return attribute{}, i, false
+				}
+
+				escaped = true
+				j++
+			case quote:
+				value := s[start+1 : j]
+				if escaped {
+					value = unescapeValue(value, quote)
+				}
+
+				quoting := DoubleQuoted
+				if quote == '\'' {
+					quoting = SingleQuoted
+				}
+
+				return attribute{key: key, value: value, quoting: quoting}, j + 1, true
+			}
+		}
+
+		return attribute{}, i, false
+	default:
+		j := start
+		for j < len(s) && !isSpace(s[j]) && s[j] != ',' && s[j] != '"' && s[j] != '\'' {
+			j++
+		}
+
+		if j == start {
+			return attribute{}, i, false
+		}
+
+		// Unquoted values may end with whitespace that \s does not match,
+		// such as \v. It is trimmed, as it would be at the end of a line, so
+		// that the value reads the same wherever it is written
+		value := strings.TrimRightFunc(s[start:j], unicode.IsSpace)
+
+		return attribute{key: key, value: value, quoting: Unquoted}, j, true
+	}
+}
+
+// appendAttribute appends attr to attrs, unless it is an unquoted attribute
+// whose value was only whitespace.
+func appendAttribute(attrs []attribute, attr attribute) []attribute {
+	if attr.quoting == Unquoted && attr.value == "" {
+		return attrs
+	}
+
+	return append(attrs, attr)
+}
+
+// lexKey returns the index following the attribute key starting at s[i], or i
+// if there is none. Keys are made of letters, numbers, underscores and
+// hyphens.
+func lexKey(s string, i int) int {
+	for i < len(s) {
+		if c := s[i]; c < utf8.RuneSelf {
+			if !isDigit(c) && !('a' <= c && c <= 'z') && !('A' <= c && c <= 'Z') && c != '_' && c != '-' {
+				break
+			}
+
+			i++
+
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if !unicode.IsLetter(r) && !unicode.IsNumber(r) {
+			break
+		}
+
+		i += size
+	}
+
+	return i
+}
+
+// isSpace reports whether c is a whitespace character as matched by \s.
+func isSpace(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\f', '\r':
+		return true
+	default:
+		return false
+	}
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
@@ -0,0 +1,369 @@
+package m3u
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Severity ranks the importance of a lint finding.
+type Severity int
+
+const (
+	// SeverityInfo marks findings that are worth knowing about.
+	SeverityInfo Severity = iota
+	// SeverityWarning marks findings that are likely to cause playback issues.
+	SeverityWarning
+	// SeverityError marks findings that are known to break players.
+	SeverityError
+)
+
+// String returns the lower-case name of the severity.
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
+// ParseSeverity parses the lower-case name of a severity.
+func ParseSeverity(s string) (Severity, error) {
+	switch s {
+	case "info":
+		return SeverityInfo, nil
+	case "warning":
+		return SeverityWarning, nil
+	case "error":
+		return SeverityError, nil
+	default:
+		return 0, fmt.Errorf("unknown severity %q", s)
+	}
+}
+
+// Lint rule IDs.
+const (
+	LintDuplicateTVGID      = "duplicate-tvg-id"
+	LintEmptyName           = "empty-name"
+	LintRelativeURL         = "relative-url"
+	LintNonHTTPScheme       = "non-http-scheme"
+	LintMissingLogo         = "missing-logo"
+	LintInvalidURLAttribute = "invalid-url-attribute"
+	LintInconsistentLength  = "inconsistent-length"
+	LintAttributeTypo       = "attribute-typo"
+)
+
+// LintRule describes a check performed by a Linter.
+type LintRule struct {
+	ID          string
+	Severity    Severity
+	Enabled     bool
+	Description string
+}
+
+var lintRules = []LintRule{
+	{LintDuplicateTVGID, SeverityWarning, true, "several tracks share the same tvg-id"},
+	{LintEmptyName, SeverityWarning, true, "a track has an empty name"},
+	{LintRelativeURL, SeverityWarning, true, "a track URL is relative"},
+	{LintNonHTTPScheme, SeverityInfo, true, "a track URL uses a scheme other than http or https"},
+	{LintMissingLogo, SeverityInfo, false, "a track has no tvg-logo"},
+	{LintInvalidURLAttribute, SeverityWarning, true, "a URL attribute failed to parse and was kept as an extra attribute"},
+	{LintInconsistentLength, SeverityInfo, true, "track lengths mix live and finite values, or use unusual values"},
+	{LintAttributeTypo, SeverityWarning, true, "an unknown attribute looks like a misspelled known one"},
+}
+
+// LintRules returns the rules checked by a Linter, with their default
+// severity and enabled state.
+func LintRules() []LintRule {
+	return slices.Clone(lintRules)
+}
+
+// knownAttributes lists the attributes commonly understood by players.
+var knownAttributes = []string{
+	"audio-track",
+	"catchup",
+	"catchup-days",
+	"catchup-source",
+	"group-title",
+	"parent-code",
+	"radio",
+	"timeshift",
+	"tvg-chno",
+	"tvg-country",
+	"tvg-id",
+	"tvg-language",
+	"tvg-logo",
+	"tvg-name",
+	"tvg-rec",
+	"tvg-shift",
+	"tvg-url",
+	"url-tvg",
+	"x-tvg-url",
+}
+
+// Finding is a problem reported by a Linter.
+type Finding struct {
+	Rule       string
+	Severity   Severity
+	Message    string
+	LineNumber int
+	// Track is the index of the track in the playlist, or -1 for findings
+	// about the playlist header.
+	Track int
+}
+
+// String formats the finding as "line N: severity: message [rule]".
+func (f Finding) String() string {
+	return fmt.Sprintf("line %d: %s: %s [%s]", f.LineNumber, f.Severity, f.Message, f.Rule)
+}
+
+// Linter reports problems in playlists that decode successfully but are
+// known to confuse players. The zero value runs the default rules.
+type Linter struct {
+	// Enabled overrides whether a rule runs, keyed by rule ID.
+	Enabled map[string]bool
+	// Severity overrides the severity of a rule, keyed by rule ID.
+	Severity map[string]Severity
+}
+
+// Lint decodes a playlist from r and returns the findings of the default
+// rules.
+func Lint(r io.Reader) ([]Finding, error) {
+	return (&Linter{}).Lint(r)
+}
+
+// Lint decodes a playlist from r and returns the findings of the enabled rules
+// in line order. If the playlist fails to decode, the decoding error is
+// returned along with the findings gathered up to that point.
+func (l *Linter) Lint(r io.Reader) ([]Finding, error) {
+	var (
+		findings []Finding
+		lines    [][2]int
+	)
+
+	decoder := NewDecoder(r)
+	decoder.onTrack = func(extinfLineNumber, urlLineNumber int) {
+		lines = append(lines, [2]int{extinfLineNumber, urlLineNumber})
+	}
+
+	playlist := &Playlist{}
+	decodeErr := decoder.Decode(playlist)
+
+	for _, warning := range decoder.Warnings() {
+		// The header is always on the first line. Other warnings belong to the
+		// first track whose URL line is not before them, or to the track after
+		// the decoded ones if it failed to decode
+		track := -1
+		if warning.LineNumber != 1 {
+			track = slices.IndexFunc(lines, func(l [2]int) bool { return l[1] >= warning.LineNumber })
+			if track == -1 {
+				track = len(lines)
+			}
+		}
+
+		findings = append(findings, Finding{
+			Rule:       LintInvalidURLAttribute,
+			Message:    fmt.Sprintf("%s value %q is not a valid URL", warning.Attribute, warning.Value),
+			LineNumber: warning.LineNumber,
+			Track:      track,
+		})
+	}
+
+	findings = append(findings, lintTracks(playlist.Tracks, lines)...)
+
+	enabled := findings[:0]
+	for _, finding := range findings {
+		if l.enabled(finding.Rule) {
+			finding.Severity = l.severity(finding.Rule)
+			enabled = append(enabled, finding)
+		}
+	}
+
+	slices.SortStableFunc(enabled, func(a, b Finding) int {
+		return a.LineNumber - b.LineNumber
+	})
+
+	return enabled, decodeErr
+}
+
+func (l *Linter) enabled(id string) bool {
+	if enabled, ok := l.Enabled[id]; ok {
+		return enabled
+	}
+
+	for _, rule := range lintRules {
+		if rule.ID == id {
+			return rule.Enabled
+		}
+	}
+
+	return false
+}
+
+func (l *Linter) severity(id string) Severity {
+	if severity, ok := l.Severity[id]; ok {
+		return severity
+	}
+
+	for _, rule := range lintRules {
+		if rule.ID == id {
+			return rule.Severity
+		}
+	}
+
+	return SeverityWarning
+}
+
+// lintTracks checks the decoded tracks. lines holds the line numbers of the
+// `#EXTINF` directive and URL of each track.
+func lintTracks(tracks []Track, lines [][2]int) []Finding {
+	var findings []Finding
+
+	report := func(rule string, track, lineNumber int, format string, args ...any) {
+		findings = append(findings, Finding{
+			Rule:       rule,
+			Message:    fmt.Sprintf(format, args...),
+			LineNumber: lineNumber,
+			Track:      track,
+		})
+	}
+
+	firstTVGID := make(map[string]int)
+	lengthKinds := make(map[string]int)
+
+	for i, track := range tracks {
+		extinfLineNumber, urlLineNumber := lines[i][0], lines[i][1]
+
+		if track.TVGID != nil && *track.TVGID != "" {
+			if first, ok := firstTVGID[*track.TVGID]; ok {
+				report(LintDuplicateTVGID, i, extinfLineNumber,
+					"tvg-id %q is already used on line %d", *track.TVGID, lines[first][0])
+			} else {
+				firstTVGID[*track.TVGID] = i
+			}
+		}
+
+		if strings.TrimSpace(track.Name) == "" {
+			report(LintEmptyName, i, extinfLineNumber, "track has an empty name")
+		}
+
+		if track.URL != nil {
+			if !track.URL.IsAbs() {
+				report(LintRelativeURL, i, urlLineNumber, "URL %q is relative", track.URL)
+			} else if track.URL.Scheme != "http" && track.URL.Scheme != "https" {
+				report(LintNonHTTPScheme, i, urlLineNumber, "URL uses the %q scheme", track.URL.Scheme)
+			}
+		}
+
+		if _, ok := track.ExtraAttributes["tvg-logo"]; track.TVGLogo == nil && !ok {
+			report(LintMissingLogo, i, extinfLineNumber, "track has no tvg-logo")
+		}
+
+		for _, key := range slices.Sorted(maps.Keys(track.ExtraAttributes)) {
+			if suggestion := suggestAttribute(key); suggestion != "" {
+				report(LintAttributeTypo, i, extinfLineNumber,
+					"unknown attribute %q, did you mean %q?", key, suggestion)
+			}
+		}
+
+		lengthKinds[lengthKind(track.Length)]++
+	}
+
+	// Report lengths that differ from the kind used by most tracks
+	majority := ""
+	for _, kind := range []string{"live", "zero", "finite"} {
+		if lengthKinds[kind] > lengthKinds[majority] {
+			majority = kind
+		}
+	}
+
+	for i, track := range tracks {
+		switch kind := lengthKind(track.Length); {
+		case kind == "invalid":
+			report(LintInconsistentLength, i, lines[i][0],
+				"length %v is negative but not -1", track.Length)
+		case kind != majority:
+			report(LintInconsistentLength, i, lines[i][0],
+				"length %v is %s while most tracks are %s", track.Length, kind, majority)
+		}
+	}
+
+	return findings
+}
+
+// lengthKind classifies a track length as "live" (-1), "zero", "finite" or
+// "invalid".
+func lengthKind(length float64) string {
+	switch {
+	case length == -1:
+		return "live"
+	case length == 0:
+		return "zero"
+	case length > 0:
+		return "finite"
+	default:
+		return "invalid"
+	}
+}
+
+// suggestAttribute returns the known attribute that key is most likely a
+// misspelling of, or the empty string if key is known or not close to any
+// known attribute.
+func suggestAttribute(key string) string {
+	if slices.Contains(knownAttributes, key) {
+		return ""
+	}
+
+	normalized := strings.ReplaceAll(strings.ToLower(key), "_", "-")
+	if slices.Contains(knownAttributes, normalized) {
+		return normalized
+	}
+
+	if len(normalized) < 5 {
+		return ""
+	}
+
+	for _, known := range knownAttributes {
+		if editDistance(normalized, known) == 1 {
+			return known
+		}
+	}
+
+	return ""
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
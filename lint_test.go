@@ -0,0 +1,138 @@
+package m3u_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sherif-fanous/m3u"
+)
+
+func TestLint(t *testing.T) {
+	t.Parallel()
+
+	input := `#EXTM3U url-tvg="http://127.0.0.1/epg.xml"
+#EXTINF:-1 tvg-id="channel-1" tvg-logo="http://127.0.0.1/logos/live_stream_1.png",Channel 1
+http://127.0.0.1/stream_1
+#EXTINF:-1 tvg-id="channel-1" tvg-logo="http://127.0.0.1/logos/%zz.png" group_title="Group 1",Channel 2
+udp://239.0.0.1:1234
+#EXTINF:120 tvg-logo="http://127.0.0.1/logos/live_stream_3.png",
+streams/stream_3.ts
+`
+
+	findings, err := m3u.Lint(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Failed to lint: %v", err)
+	}
+
+	var rules []string
+	for _, finding := range findings {
+		rules = append(rules, finding.Rule)
+	}
+
+	expectedRules := []string{
+		m3u.LintInvalidURLAttribute,
+		m3u.LintDuplicateTVGID,
+		m3u.LintAttributeTypo,
+		m3u.LintNonHTTPScheme,
+		m3u.LintEmptyName,
+		m3u.LintInconsistentLength,
+		m3u.LintRelativeURL,
+	}
+
+	if diff := cmp.Diff(rules, expectedRules); diff != "" {
+		t.Error(diff)
+	}
+
+	expectedTypo := m3u.Finding{
+		Rule:       m3u.LintAttributeTypo,
+		Severity:   m3u.SeverityWarning,
+		Message:    `unknown attribute "group_title", did you mean "group-title"?`,
+		LineNumber: 4,
+		Track:      1,
+	}
+
+	if diff := cmp.Diff(findings[2], expectedTypo); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestLintInvalidImage(t *testing.T) {
+	t.Parallel()
+
+	input := `#EXTM3U
+#EXTINF:-1,Channel 1
+#EXTIMG:http://[::1
+http://127.0.0.1/stream_1
+#EXTINF:-1,Channel 2
+http://127.0.0.1/stream_2
+#EXTINF:-1,Channel 3
+http://127.0.0.1/stream_3
+`
+
+	findings, err := m3u.Lint(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Failed to lint: %v", err)
+	}
+
+	expectedFindings := []m3u.Finding{
+		{
+			Rule:       m3u.LintInvalidURLAttribute,
+			Severity:   m3u.SeverityWarning,
+			Message:    `#EXTIMG value "http://[::1" is not a valid URL`,
+			LineNumber: 3,
+			Track:      0,
+		},
+	}
+
+	if diff := cmp.Diff(findings, expectedFindings); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestLinterOverrides(t *testing.T) {
+	t.Parallel()
+
+	input := `#EXTM3U
+#EXTINF:-1,Channel 1
+http://127.0.0.1/stream_1
+#EXTINF:-1,
+http://127.0.0.1/stream_2
+`
+
+	linter := &m3u.Linter{
+		Enabled: map[string]bool{
+			m3u.LintMissingLogo: true,
+			m3u.LintEmptyName:   false,
+		},
+		Severity: map[string]m3u.Severity{
+			m3u.LintMissingLogo: m3u.SeverityError,
+		},
+	}
+
+	findings, err := linter.Lint(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Failed to lint: %v", err)
+	}
+
+	expectedFindings := []m3u.Finding{
+		{
+			Rule:       m3u.LintMissingLogo,
+			Severity:   m3u.SeverityError,
+			Message:    "track has no tvg-logo",
+			LineNumber: 2,
+			Track:      0,
+		},
+		{
+			Rule:       m3u.LintMissingLogo,
+			Severity:   m3u.SeverityError,
+			Message:    "track has no tvg-logo",
+			LineNumber: 4,
+			Track:      1,
+		},
+	}
+
+	if diff := cmp.Diff(findings, expectedFindings); diff != "" {
+		t.Error(diff)
+	}
+}
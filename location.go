@@ -0,0 +1,253 @@
+package m3u
+
+import (
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ResolveLocation parses the location s of a track, such as the URL line of an
+// #EXTINF directive block, and resolves it against base.
+//
+// Locations with a scheme, such as http://host/a.ts or file:///music/a.mp3,
+// are parsed as URLs. Windows drive paths (C:\Music\a.mp3) and UNC paths
+// (\\server\share\a.mp3) are recognized on every platform and returned as
+// file URLs. Other locations are relative references: when base is a file URL
+// or nil, they are file paths, in which backslashes separate directories and
+// characters such as '#', '%' and '?' are literal; otherwise they are URL
+// references.
+//
+// Relative references are returned unresolved when base is nil.
+func ResolveLocation(s string, base *url.URL) (*url.URL, error) {
+	if hasScheme(s) {
+		u, err := parseURL(s)
+		if err != nil {
+			return nil, err
+		}
+
+		// file://C:/a.mp3 is a common misspelling of file:///C:/a.mp3
+		if u.Scheme == "file" && isDrive(u.Host) {
+			u.Path = "/" + u.Host + u.Path
+			u.Host = ""
+		}
+
+		return u, nil
+	}
+
+	var ref *url.URL
+
+	if base == nil || base.Scheme == "file" {
+		p := strings.ReplaceAll(s, `\`, "/")
+		if isWindowsPath(p) {
+			return fileURL(p), nil
+		}
+
+		ref = &url.URL{Path: p}
+	} else {
+		var err error
+		if ref, err = parseURL(s); err != nil {
+			return nil, err
+		}
+	}
+
+	if base == nil {
+		return ref, nil
+	}
+
+	return base.ResolveReference(ref), nil
+}
+
+// parseLocation parses the location of a track decoded with WithFilePaths.
+// Locations with a scheme are parsed as URLs. Others are file paths, kept as is
+// in the Path of the returned URL, as url.Parse would read characters such as
+// '#' and '%' in file names as a fragment or an escape, and Windows drive
+// letters as schemes.
+func parseLocation(s string) (*url.URL, error) {
+	if hasScheme(s) {
+		return parseURL(s)
+	}
+
+	return &url.URL{Path: s}, nil
+}
+
+// locationString returns the text of the track location u, which
+// parseLocation parses back to u. URLs holding only a path are file paths,
+// written as is.
+func locationString(u *url.URL) string {
+	if *u != (url.URL{Path: u.Path}) || u.Path == "" {
+		return urlString(u)
+	}
+
+	// Keep the path from reading as a URL or a directive
+	if hasScheme(u.Path) || u.Path[0] == '#' {
+		return "./" + u.Path
+	}
+
+	return u.Path
+}
+
+// RelativeLocation returns the location of u relative to base, and reports
+// whether there is one. u must have the scheme, host and user of base. The
+// location of a file URL is a file path, with slashes separating directories,
+// which ResolveLocation resolves back to u; that of other URLs is a URL
+// reference.
+func RelativeLocation(u, base *url.URL) (string, bool) {
+	if u == nil || base == nil || u.Scheme == "" || u.Opaque != "" || base.Opaque != "" ||
+		u.Scheme != base.Scheme || u.Host != base.Host || u.User.String() != base.User.String() {
+		return "", false
+	}
+
+	// File paths have no query or fragment
+	file := u.Scheme == "file"
+	if file && (u.RawQuery != "" || u.Fragment != "") {
+		return "", false
+	}
+
+	rel, ok := relativePath(base.Path[:strings.LastIndex(base.Path, "/")+1], u.Path)
+	if !ok {
+		return "", false
+	}
+
+	if !file {
+		return (&url.URL{Path: rel, RawQuery: u.RawQuery, Fragment: u.Fragment}).String(), true
+	}
+
+	// Keep the path from reading as a URL, a Windows drive path or a directive
+	if hasScheme(rel) || isWindowsPath(rel) || strings.HasPrefix(rel, "#") {
+		rel = "./" + rel
+	}
+
+	return rel, true
+}
+
+// relativePath returns the path of target relative to the directory dir, which
+// ends with a slash, and reports whether there is one. Paths on different
+// Windows drives are not relative to each other.
+func relativePath(dir, target string) (string, bool) {
+	// Cleaning removes the empty and dot segments, which would otherwise be
+	// counted as directories
+	dirSegments := strings.Split(strings.TrimSuffix(path.Clean(dir), "/"), "/")
+	targetSegments := strings.Split(path.Clean(target), "/")
+
+	// Drives are case-insensitive
+	dirDrive, targetDrive := drive(dirSegments), drive(targetSegments)
+	if dirDrive != targetDrive {
+		return "", false
+	}
+
+	if dirDrive != "" {
+		dirSegments[1], targetSegments[1] = dirDrive, targetDrive
+	}
+
+	common := 0
+	for common < len(dirSegments) && common < len(targetSegments)-1 && dirSegments[common] == targetSegments[common] {
+		common++
+	}
+
+	// Relative paths cannot climb above the root, or drive, of target
+	if common == 0 || (common == 1 && drive(targetSegments) != "") {
+		return "", false
+	}
+
+	rel := strings.Repeat("../", len(dirSegments)-common) + strings.Join(targetSegments[common:], "/")
+	if rel == "" {
+		rel = "./"
+	}
+
+	return rel, true
+}
+
+// drive returns the upper-case Windows drive of the path split into segments,
+// such as "C:" for /c:/Music, or "" if it has none.
+func drive(segments []string) string {
+	if len(segments) > 1 && segments[0] == "" && isDrive(segments[1]) {
+		return strings.ToUpper(segments[1])
+	}
+
+	return ""
+}
+
+// dirURL returns the file URL of the directory dir, which ends with a slash so
+// that references resolve inside it. Relative directories are made absolute
+// against the working directory.
+func dirURL(dir string) *url.URL {
+	p := strings.ReplaceAll(dir, `\`, "/")
+
+	if !isWindowsPath(p) {
+		if abs, err := filepath.Abs(dir); err == nil {
+			p = filepath.ToSlash(abs)
+		}
+	}
+
+	u := fileURL(p)
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+
+	return u
+}
+
+// fileURL returns the file URL of the absolute, slash-separated path p, which
+// is a Windows drive path such as C:/Music, a UNC path such as
+// //server/share/Music, or a path such as /music.
+func fileURL(p string) *url.URL {
+	if rest, ok := strings.CutPrefix(p, "//"); ok {
+		host, path, _ := strings.Cut(rest, "/")
+
+		return &url.URL{Scheme: "file", Host: host, Path: "/" + path}
+	}
+
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+
+	return &url.URL{Scheme: "file", Path: p}
+}
+
+// filePath returns the slash-separated path of the file URL u, such as
+// /music/a.mp3, C:/Music/a.mp3 or //server/share/a.mp3. It reverses fileURL.
+func filePath(u *url.URL) string {
+	if u.Host != "" && u.Host != "localhost" {
+		return "//" + u.Host + u.Path
+	}
+
+	if p := strings.TrimPrefix(u.Path, "/"); isWindowsPath(p) || isDrive(p) {
+		return p
+	}
+
+	return u.Path
+}
+
+// hasScheme reports whether s starts with a URL scheme of two characters or
+// more, which tells it apart from a Windows drive path.
+func hasScheme(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		switch {
+		case 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z':
+		case '0' <= c && c <= '9' || c == '+' || c == '-' || c == '.':
+			if i == 0 {
+				return false
+			}
+		case c == ':':
+			return i >= 2
+		default:
+			return false
+		}
+	}
+
+	return false
+}
+
+// isWindowsPath reports whether the slash-separated path p is a Windows drive
+// path, such as C:/Music, or a UNC path, such as //server/share.
+func isWindowsPath(p string) bool {
+	return len(p) > 2 && isDrive(p[:2]) && p[2] == '/' || strings.HasPrefix(p, "//")
+}
+
+// isDrive reports whether s is a Windows drive, such as C:.
+func isDrive(s string) bool {
+	return len(s) == 2 && s[1] == ':' && ('a' <= s[0] && s[0] <= 'z' || 'A' <= s[0] && s[0] <= 'Z')
+}
@@ -0,0 +1,299 @@
+package m3u_test
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sherif-fanous/m3u"
+)
+
+func TestResolveLocation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		location string
+		base     string
+		expected *url.URL
+	}{
+		{
+			name:     "URL",
+			location: "http://127.0.0.1/stream_1?token=a%20b",
+			base:     "file:///music/",
+			expected: &url.URL{Scheme: "http", Host: "127.0.0.1", Path: "/stream_1", RawQuery: "token=a%20b"},
+		},
+		{
+			name:     "Windows drive path",
+			location: `C:\Music\Album\01.mp3`,
+			expected: &url.URL{Scheme: "file", Path: "/C:/Music/Album/01.mp3"},
+		},
+		{
+			name:     "UNC path",
+			location: `\\server\share\Album\01.mp3`,
+			expected: &url.URL{Scheme: "file", Host: "server", Path: "/share/Album/01.mp3"},
+		},
+		{
+			name:     "file URL",
+			location: "file:///home/user/Music/01%20Intro.mp3",
+			expected: &url.URL{Scheme: "file", Path: "/home/user/Music/01 Intro.mp3"},
+		},
+		{
+			name:     "file URL with a drive as host",
+			location: "file://C:/Music/01.mp3",
+			expected: &url.URL{Scheme: "file", Path: "/C:/Music/01.mp3"},
+		},
+		{
+			name:     "relative path",
+			location: "../Album/01 Song #1 100%.mp3",
+			base:     "file:///home/user/Music/Playlists/",
+			expected: &url.URL{Scheme: "file", Path: "/home/user/Music/Album/01 Song #1 100%.mp3"},
+		},
+		{
+			name:     "relative Windows path",
+			location: `..\Album\01.mp3`,
+			base:     "file:///C:/Music/Playlists/",
+			expected: &url.URL{Scheme: "file", Path: "/C:/Music/Album/01.mp3"},
+		},
+		{
+			name:     "relative URL reference",
+			location: "../live/stream%201.ts?token=1",
+			base:     "http://127.0.0.1/lists/playlist.m3u",
+			expected: &url.URL{Scheme: "http", Host: "127.0.0.1", Path: "/live/stream 1.ts", RawQuery: "token=1"},
+		},
+		{
+			name:     "relative path without base",
+			location: `Album\01 Song #1.mp3`,
+			expected: &url.URL{Path: "Album/01 Song #1.mp3"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			var base *url.URL
+			if test.base != "" {
+				base = makeURL(t, test.base)
+			}
+
+			u, err := m3u.ResolveLocation(test.location, base)
+			if err != nil {
+				t.Fatalf("Failed to resolve location: %v", err)
+			}
+
+			if diff := cmp.Diff(u, test.expected); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestRelativeLocation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		u        *url.URL
+		base     string
+		expected string
+		ok       bool
+		// resolved is the URL rel resolves to, if not u
+		resolved string
+	}{
+		{
+			name:     "file in parent directory",
+			u:        &url.URL{Scheme: "file", Path: "/home/user/Music/Album/01 Song #1.mp3"},
+			base:     "file:///home/user/Music/Playlists/playlist.m3u",
+			expected: "../Album/01 Song #1.mp3",
+			ok:       true,
+		},
+		{
+			name:     "file in same directory",
+			u:        &url.URL{Scheme: "file", Path: "/music/#1.mp3"},
+			base:     "file:///music/",
+			expected: "./#1.mp3",
+			ok:       true,
+		},
+		{
+			name:     "drives differing in case",
+			u:        &url.URL{Scheme: "file", Path: "/c:/Music/01.mp3"},
+			base:     "file:///C:/Music/",
+			expected: "01.mp3",
+			ok:       true,
+			resolved: "file:///C:/Music/01.mp3",
+		},
+		{
+			name: "file on another drive",
+			u:    &url.URL{Scheme: "file", Path: "/D:/Music/01.mp3"},
+			base: "file:///C:/Music/",
+		},
+		{
+			name:     "URL on same host",
+			u:        makeURL(t, "http://127.0.0.1/live/stream%201.ts?token=1"),
+			base:     "http://127.0.0.1/lists/playlist.m3u",
+			expected: "../live/stream%201.ts?token=1",
+			ok:       true,
+		},
+		{
+			name: "URL on another host",
+			u:    makeURL(t, "http://127.0.0.2/live/1.ts"),
+			base: "http://127.0.0.1/lists/playlist.m3u",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			base := makeURL(t, test.base)
+
+			rel, ok := m3u.RelativeLocation(test.u, base)
+			if ok != test.ok || rel != test.expected {
+				t.Fatalf("Expected %q, %t, got %q, %t", test.expected, test.ok, rel, ok)
+			}
+
+			if !ok {
+				return
+			}
+
+			u, err := m3u.ResolveLocation(rel, base)
+			if err != nil {
+				t.Fatalf("Failed to resolve location: %v", err)
+			}
+
+			resolved := test.resolved
+			if resolved == "" {
+				resolved = test.u.String()
+			}
+
+			if u.String() != resolved {
+				t.Errorf("Expected %q to resolve to %q, got %q", rel, resolved, u)
+			}
+		})
+	}
+}
+
+func TestDecodeWithBaseDir(t *testing.T) {
+	t.Parallel()
+
+	input := `#EXTM3U
+#EXTINF:215,Artist - Song #1
+../Album/01 Song #1.mp3
+#EXTINF:180,Artist - Song 2
+C:\Music\Album\02.mp3
+#EXTINF:-1,Radio
+http://127.0.0.1/radio.mp3
+`
+
+	var playlist m3u.Playlist
+	if err := m3u.NewDecoder(strings.NewReader(input), m3u.WithBaseDir("/music/playlists")).Decode(&playlist); err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+
+	expected := []*url.URL{
+		{Scheme: "file", Path: "/music/Album/01 Song #1.mp3"},
+		{Scheme: "file", Path: "/C:/Music/Album/02.mp3"},
+		makeURL(t, "http://127.0.0.1/radio.mp3"),
+	}
+
+	for i, track := range playlist.Tracks {
+		if diff := cmp.Diff(track.URL, expected[i]); diff != "" {
+			t.Errorf("Track %d: %s", i, diff)
+		}
+	}
+
+	var b strings.Builder
+	if err := m3u.NewEncoder(&b, m3u.WithRelativeToDir("/music/playlists")).Encode(&playlist, m3u.M3U); err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+
+	expectedOutput := strings.Replace(input, `C:\Music\Album\02.mp3`, "file:///C:/Music/Album/02.mp3", 1)
+	if diff := cmp.Diff(b.String(), expectedOutput); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestFilePathRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	input := `#EXTM3U
+#EXTINF:215,Artist - Song #1
+../Album/01 Song #1 (100%).mp3
+#EXTINF:180,Artist - Song 2
+C:\Music\Album\02.mp3
+`
+
+	var playlist m3u.Playlist
+	if err := m3u.NewDecoder(strings.NewReader(input), m3u.WithFilePaths()).Decode(&playlist); err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+
+	if path := playlist.Tracks[0].URL.Path; path != "../Album/01 Song #1 (100%).mp3" {
+		t.Errorf("Expected the path to be kept as is, got %q", path)
+	}
+
+	if diff := cmp.Diff(m3u.NewCompactPlaylist(&playlist).Playlist(), &playlist); diff != "" {
+		t.Error(diff)
+	}
+
+	var b strings.Builder
+	if err := m3u.NewEncoder(&b, m3u.WithFilePathOutput()).Encode(&playlist, m3u.M3U); err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+
+	if diff := cmp.Diff(b.String(), input); diff != "" {
+		t.Error(diff)
+	}
+
+	// Without WithFilePaths, the '%' is an invalid escape
+	if _, err := m3u.Unmarshal([]byte(input)); !errors.Is(err, m3u.ErrInvalidURL) {
+		t.Errorf("Expected ErrInvalidURL, got: %v", err)
+	}
+}
+
+func TestDecodeRelativeURLs(t *testing.T) {
+	t.Parallel()
+
+	input := `#EXTM3U
+#EXTINF:-1,Channel 1
+//cdn.example.com/live/1.ts?token=secret
+#EXTINF:-1,Channel 2
+/live/2.ts?token=secret#f
+`
+
+	playlist, err := m3u.Unmarshal([]byte(input))
+	if err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+
+	expected := []*url.URL{
+		{Host: "cdn.example.com", Path: "/live/1.ts", RawQuery: "token=secret"},
+		{Path: "/live/2.ts", RawQuery: "token=secret", Fragment: "f"},
+	}
+
+	for i, track := range playlist.Tracks {
+		if diff := cmp.Diff(track.URL, expected[i]); diff != "" {
+			t.Errorf("Track %d: %s", i, diff)
+		}
+	}
+
+	data, err := m3u.Marshal(playlist, m3u.M3U)
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+
+	if diff := cmp.Diff(string(data), input); diff != "" {
+		t.Error(diff)
+	}
+
+	playlist.Redact()
+
+	for i, track := range playlist.Tracks {
+		if strings.Contains(track.URL.String(), "secret") {
+			t.Errorf("Track %d: expected the token to be masked, got %q", i, track.URL)
+		}
+	}
+}
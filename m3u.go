@@ -14,24 +14,71 @@ const (
 	M3UPlus PlaylistType = "M3UPlus"
 )
 
+// Quoting is the quoting style of an attribute value.
+type Quoting int
+
+const (
+	// DoubleQuoted values are enclosed in double quotes: key="value".
+	DoubleQuoted Quoting = iota
+	// SingleQuoted values are enclosed in single quotes: key='value'.
+	SingleQuoted
+	// Unquoted values are written as is: key=value.
+	Unquoted
+)
+
 // Playlist represents an M3U playlist.
 type Playlist struct {
 	TVGURL          *url.URL
 	XTVGURL         *url.URL
 	ExtraAttributes map[string]string
 	Tracks          []Track
+	// Title, Album, Artist, Genre and Image hold the #PLAYLIST, #EXTALB,
+	// #EXTART, #EXTGENRE and #EXTIMG directives found before the first track,
+	// which apply to the whole playlist.
+	Title  *string
+	Album  *string
+	Artist *string
+	Genre  *string
+	Image  *url.URL
+	// AttributeQuoting records the quoting style of the header attributes
+	// that were not double-quoted in the source playlist, keyed by attribute
+	// name. It is used by encoders in lossless mode.
+	AttributeQuoting map[string]Quoting
+	// AttributeOrder records the names of the header attributes in the order
+	// they appeared in the source playlist, when decoded with
+	// WithRecordedAttributeOrder. It is used by encoders writing attributes in
+	// AttributeOrderOriginal.
+	AttributeOrder []string
 }
 
 // Track represents a single entry in an M3U playlist.
 type Track struct {
-	Length          float64
-	Name            string
-	TVGID           *string
-	TVGName         *string
-	TVGLanguage     *string
-	TVGLogo         *url.URL
-	GroupTitle      *string
-	URL             *url.URL
+	Length      float64
+	Name        string
+	TVGID       *string
+	TVGName     *string
+	TVGLanguage *string
+	TVGLogo     *url.URL
+	GroupTitle  *string
+	URL         *url.URL
+	// AlternateURLs are the fallback locations of the track, in order, to
+	// try when URL fails.
+	AlternateURLs []*url.URL
+	// Album, Artist, Genre and Image hold the #EXTALB, #EXTART, #EXTGENRE and
+	// #EXTIMG directives of the track.
+	Album           *string
+	Artist          *string
+	Genre           *string
+	Image           *url.URL
 	ExtraAttributes map[string]string
 	ExtraDirectives []string
+	// AttributeQuoting records the quoting style of the attributes that were
+	// not double-quoted in the source playlist, keyed by attribute name. It is
+	// used by encoders in lossless mode.
+	AttributeQuoting map[string]Quoting
+	// AttributeOrder records the names of the attributes in the order they
+	// appeared in the source playlist, when decoded with
+	// WithRecordedAttributeOrder. It is used by encoders writing attributes in
+	// AttributeOrderOriginal.
+	AttributeOrder []string
 }
@@ -1,7 +1,11 @@
 package m3u_test
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
+	"io"
 	"net/url"
 	"strings"
 	"testing"
@@ -198,6 +202,189 @@ http://127.0.0.1/stream_2
 	}
 }
 
+func TestEncodeOptions(t *testing.T) {
+	t.Parallel()
+
+	input := `#EXTM3U x-tvg-url="http://127.0.0.1/epg.xml" tvg-shift="2"
+#EXTINF:-1 group-title="News" tvg-chno="1" tvg-id="channel-1" tvg-name="Channel 1",Channel 1
+#EXTVLCOPT:http-referrer=http://example.com/
+http://127.0.0.1/stream_1
+`
+
+	var playlist m3u.Playlist
+	if err := m3u.NewDecoder(strings.NewReader(input), m3u.WithRecordedAttributeOrder()).Decode(&playlist); err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		opts         []m3u.EncoderOption
+		playlistType m3u.PlaylistType
+		expected     string
+	}{
+		{
+			name:         "fixed order",
+			playlistType: m3u.M3UPlus,
+			expected: `#EXTM3U x-tvg-url="http://127.0.0.1/epg.xml" tvg-shift="2"
+#EXTINF:-1 tvg-id="channel-1" tvg-name="Channel 1" group-title="News" tvg-chno="1",Channel 1
+#EXTVLCOPT:http-referrer=http://example.com/
+http://127.0.0.1/stream_1
+`,
+		},
+		{
+			name:         "sorted order",
+			opts:         []m3u.EncoderOption{m3u.WithAttributeOrder(m3u.AttributeOrderSorted)},
+			playlistType: m3u.M3UPlus,
+			expected: `#EXTM3U tvg-shift="2" x-tvg-url="http://127.0.0.1/epg.xml"
+#EXTINF:-1 group-title="News" tvg-chno="1" tvg-id="channel-1" tvg-name="Channel 1",Channel 1
+#EXTVLCOPT:http-referrer=http://example.com/
+http://127.0.0.1/stream_1
+`,
+		},
+		{
+			name:         "original order",
+			opts:         []m3u.EncoderOption{m3u.WithAttributeOrder(m3u.AttributeOrderOriginal)},
+			playlistType: m3u.M3UPlus,
+			expected:     input,
+		},
+		{
+			name:         "custom order",
+			opts:         []m3u.EncoderOption{m3u.WithCustomAttributeOrder("tvg-chno", "tvg-name")},
+			playlistType: m3u.M3UPlus,
+			expected: `#EXTM3U x-tvg-url="http://127.0.0.1/epg.xml" tvg-shift="2"
+#EXTINF:-1 tvg-chno="1" tvg-name="Channel 1" tvg-id="channel-1" group-title="News",Channel 1
+#EXTVLCOPT:http-referrer=http://example.com/
+http://127.0.0.1/stream_1
+`,
+		},
+		{
+			name:         "included attributes",
+			opts:         []m3u.EncoderOption{m3u.WithIncludedAttributes("tvg-id", "group-title")},
+			playlistType: m3u.M3UPlus,
+			expected: `#EXTM3U
+#EXTINF:-1 tvg-id="channel-1" group-title="News",Channel 1
+#EXTVLCOPT:http-referrer=http://example.com/
+http://127.0.0.1/stream_1
+`,
+		},
+		{
+			name:         "excluded attributes",
+			opts:         []m3u.EncoderOption{m3u.WithExcludedAttributes("tvg-shift", "tvg-chno", "tvg-name")},
+			playlistType: m3u.M3UPlus,
+			expected: `#EXTM3U x-tvg-url="http://127.0.0.1/epg.xml"
+#EXTINF:-1 tvg-id="channel-1" group-title="News",Channel 1
+#EXTVLCOPT:http-referrer=http://example.com/
+http://127.0.0.1/stream_1
+`,
+		},
+		{
+			name:         "CRLF line endings",
+			opts:         []m3u.EncoderOption{m3u.WithLineEnding(m3u.CRLF)},
+			playlistType: m3u.M3U,
+			expected:     "#EXTM3U x-tvg-url=\"http://127.0.0.1/epg.xml\" tvg-shift=\"2\"\r\n#EXTINF:-1,Channel 1\r\n#EXTVLCOPT:http-referrer=http://example.com/\r\nhttp://127.0.0.1/stream_1\r\n",
+		},
+		{
+			name:         "no M3U directives",
+			opts:         []m3u.EncoderOption{m3u.WithM3UDirectives(false)},
+			playlistType: m3u.M3U,
+			expected: `#EXTM3U x-tvg-url="http://127.0.0.1/epg.xml" tvg-shift="2"
+#EXTINF:-1,Channel 1
+http://127.0.0.1/stream_1
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			var b strings.Builder
+			if err := m3u.NewEncoder(&b, test.opts...).Encode(&playlist, test.playlistType); err != nil {
+				t.Fatalf("Failed to encode: %v", err)
+			}
+
+			if diff := cmp.Diff(b.String(), test.expected); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestEncodeIncremental(t *testing.T) {
+	t.Parallel()
+
+	playlist, err := m3u.Unmarshal(makeLargePlaylist(3))
+	if err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+
+	expected, err := m3u.Marshal(playlist, m3u.M3UPlus)
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+
+	var b strings.Builder
+
+	encoder := m3u.NewEncoder(&b)
+	if err := encoder.EncodeHeader(playlist); err != nil {
+		t.Fatalf("Failed to encode header: %v", err)
+	}
+
+	for i := range playlist.Tracks {
+		if err := encoder.EncodeTrack(&playlist.Tracks[i], m3u.M3UPlus); err != nil {
+			t.Fatalf("Failed to encode track: %v", err)
+		}
+	}
+
+	if b.Len() == len(expected) {
+		t.Error("Expected the output to be buffered until Flush")
+	}
+
+	if err := encoder.Flush(); err != nil {
+		t.Fatalf("Failed to flush: %v", err)
+	}
+
+	if diff := cmp.Diff(b.String(), string(expected)); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestEncodeIncrementalErrors(t *testing.T) {
+	t.Parallel()
+
+	track := &m3u.Track{Length: -1, Name: "Channel 1", URL: makeURL(t, "http://127.0.0.1/stream_1")}
+
+	encoder := m3u.NewEncoder(io.Discard)
+	if err := encoder.EncodeTrack(track, m3u.M3U); err == nil {
+		t.Fatal("Expected encoding a track before the header to fail")
+	}
+
+	if err := encoder.Flush(); err == nil {
+		t.Error("Expected the error to be retained")
+	}
+
+	encoder = m3u.NewEncoder(failingWriter{})
+	if err := encoder.EncodeHeader(&m3u.Playlist{}); err != nil {
+		t.Fatalf("Expected buffered header not to fail, got: %v", err)
+	}
+
+	err := encoder.Flush()
+	if err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Fatalf("Expected the write error, got: %v", err)
+	}
+
+	if err := encoder.EncodeTrack(track, m3u.M3U); err == nil {
+		t.Error("Expected the error to be retained")
+	}
+}
+
 func TestExtraAttributesAndDirectives(t *testing.T) {
 	t.Parallel()
 
@@ -246,6 +433,297 @@ http://127.0.0.1/stream_1
 	}
 }
 
+func TestExtendedDirectives(t *testing.T) {
+	t.Parallel()
+
+	input := `#EXTM3U
+#PLAYLIST:Road Trip
+#EXTALB:Greatest Hits
+#EXTART:Various Artists
+#EXTGENRE:Rock
+#EXTIMG:covers/front.jpg
+#EXTINF:215,Artist 1 - Song 1
+#EXTALB:Album 1
+#EXTART:Artist 1
+#EXTGENRE:Blues
+#EXTIMG:http://127.0.0.1/covers/album_1.jpg
+#PLAYLIST:Not a track field
+music/01.mp3
+#EXTINF:187,Artist 2 - Song 2
+music/02.mp3
+`
+
+	playlist, err := m3u.Unmarshal([]byte(input))
+	if err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+
+	expectedPlaylist := &m3u.Playlist{
+		Title:  makePointer("Road Trip"),
+		Album:  makePointer("Greatest Hits"),
+		Artist: makePointer("Various Artists"),
+		Genre:  makePointer("Rock"),
+		Image:  makeURL(t, "covers/front.jpg"),
+		Tracks: []m3u.Track{
+			{
+				Length:          215,
+				Name:            "Artist 1 - Song 1",
+				URL:             makeURL(t, "music/01.mp3"),
+				Album:           makePointer("Album 1"),
+				Artist:          makePointer("Artist 1"),
+				Genre:           makePointer("Blues"),
+				Image:           makeURL(t, "http://127.0.0.1/covers/album_1.jpg"),
+				ExtraDirectives: []string{"#PLAYLIST:Not a track field"},
+			},
+			{
+				Length: 187,
+				Name:   "Artist 2 - Song 2",
+				URL:    makeURL(t, "music/02.mp3"),
+			},
+		},
+	}
+
+	if diff := cmp.Diff(playlist, expectedPlaylist); diff != "" {
+		t.Error(diff)
+	}
+
+	output, err := m3u.Marshal(playlist, m3u.M3U)
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+
+	if diff := cmp.Diff(string(output), input); diff != "" {
+		t.Error(diff)
+	}
+
+	var b strings.Builder
+	if err := m3u.NewEncoder(&b, m3u.WithM3UDirectives(false)).Encode(playlist, m3u.M3U); err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+
+	expectedOutput := `#EXTM3U
+#PLAYLIST:Road Trip
+#EXTALB:Greatest Hits
+#EXTART:Various Artists
+#EXTGENRE:Rock
+#EXTIMG:covers/front.jpg
+#EXTINF:215,Artist 1 - Song 1
+music/01.mp3
+#EXTINF:187,Artist 2 - Song 2
+music/02.mp3
+`
+	if diff := cmp.Diff(b.String(), expectedOutput); diff != "" {
+		t.Error(diff)
+	}
+
+	// Metadata directives after the last track have no track to apply to
+	_, err = m3u.Unmarshal([]byte(input + "#EXTALB:Album 3\n"))
+	if !errors.Is(err, m3u.ErrOrphanDirective) {
+		t.Errorf("Expected ErrOrphanDirective, got: %v", err)
+	}
+}
+
+func TestMetadataBeforeTracks(t *testing.T) {
+	t.Parallel()
+
+	input := `#EXTM3U
+#EXTALB:Album 1
+#EXTINF:215,Artist 1 - Song 1
+music/01.mp3
+#EXTALB:Album 2
+#EXTART:Artist 2
+#EXTIMG:http://[::1
+#EXTINF:187,Artist 2 - Song 2
+#EXTIMG:covers/album_2.jpg
+music/02.mp3
+`
+
+	var playlist m3u.Playlist
+
+	decoder := m3u.NewDecoder(strings.NewReader(input))
+	if err := decoder.Decode(&playlist); err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+
+	// Metadata directives before the first track belong to the playlist
+	expectedPlaylist := m3u.Playlist{
+		Album: makePointer("Album 1"),
+		Tracks: []m3u.Track{
+			{
+				Length: 215,
+				Name:   "Artist 1 - Song 1",
+				URL:    makeURL(t, "music/01.mp3"),
+			},
+			{
+				Length:          187,
+				Name:            "Artist 2 - Song 2",
+				URL:             makeURL(t, "music/02.mp3"),
+				Album:           makePointer("Album 2"),
+				Artist:          makePointer("Artist 2"),
+				Image:           makeURL(t, "covers/album_2.jpg"),
+				ExtraDirectives: []string{"#EXTIMG:http://[::1"},
+			},
+		},
+	}
+
+	if diff := cmp.Diff(playlist, expectedPlaylist); diff != "" {
+		t.Error(diff)
+	}
+
+	if warnings := decoder.Warnings(); len(warnings) != 1 || warnings[0].LineNumber != 7 {
+		t.Errorf("Expected a warning about line 7, got: %v", warnings)
+	}
+}
+
+func TestFallbackURLs(t *testing.T) {
+	t.Parallel()
+
+	input := `#EXTM3U
+#EXTINF:-1 tvg-id="channel-1" group-title="News",Channel 1
+#EXTVLCOPT:http-referrer=http://example.com/
+http://127.0.0.1/stream_1
+#EXTINF:-1 tvg-id="channel-1" group-title="News",Channel 1
+#EXTVLCOPT:http-referrer=http://example.com/
+http://127.0.0.2/stream_1
+#EXTINF:-1 tvg-id="channel-1" group-title="News",Channel 1
+#EXTVLCOPT:http-referrer=http://example.com/
+http://127.0.0.3/stream_1
+#EXTINF:-1 tvg-id="channel-2" group-title="News",Channel 2
+http://127.0.0.1/stream_2
+#EXTINF:-1 tvg-id="channel-1" group-title="News",Channel 1
+http://127.0.0.4/stream_1
+`
+
+	playlist := &m3u.Playlist{}
+	if err := m3u.NewDecoder(strings.NewReader(input), m3u.WithFallbackGrouping()).Decode(playlist); err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+
+	expectedPlaylist := &m3u.Playlist{
+		Tracks: []m3u.Track{
+			{
+				Length:     -1,
+				Name:       "Channel 1",
+				TVGID:      makePointer("channel-1"),
+				GroupTitle: makePointer("News"),
+				URL:        makeURL(t, "http://127.0.0.1/stream_1"),
+				AlternateURLs: []*url.URL{
+					makeURL(t, "http://127.0.0.2/stream_1"),
+					makeURL(t, "http://127.0.0.3/stream_1"),
+				},
+				ExtraDirectives: []string{"#EXTVLCOPT:http-referrer=http://example.com/"},
+			},
+			{
+				Length:     -1,
+				Name:       "Channel 2",
+				TVGID:      makePointer("channel-2"),
+				GroupTitle: makePointer("News"),
+				URL:        makeURL(t, "http://127.0.0.1/stream_2"),
+			},
+			// Entries are only grouped when consecutive and identical
+			{
+				Length:     -1,
+				Name:       "Channel 1",
+				TVGID:      makePointer("channel-1"),
+				GroupTitle: makePointer("News"),
+				URL:        makeURL(t, "http://127.0.0.4/stream_1"),
+			},
+		},
+	}
+
+	if diff := cmp.Diff(playlist, expectedPlaylist); diff != "" {
+		t.Error(diff)
+	}
+
+	if diff := cmp.Diff(m3u.NewCompactPlaylist(playlist).Playlist(), playlist); diff != "" {
+		t.Error(diff)
+	}
+
+	output, err := m3u.Marshal(playlist, m3u.M3UPlus)
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+
+	if diff := cmp.Diff(string(output), input); diff != "" {
+		t.Error(diff)
+	}
+
+	var b strings.Builder
+	if err := m3u.NewEncoder(&b, m3u.WithAlternateURLMode(m3u.AlternateURLsPrimary)).Encode(playlist, m3u.M3UPlus); err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+
+	expected := `#EXTM3U
+#EXTINF:-1 tvg-id="channel-1" group-title="News",Channel 1
+#EXTVLCOPT:http-referrer=http://example.com/
+http://127.0.0.1/stream_1
+#EXTINF:-1 tvg-id="channel-2" group-title="News",Channel 2
+http://127.0.0.1/stream_2
+#EXTINF:-1 tvg-id="channel-1" group-title="News",Channel 1
+http://127.0.0.4/stream_1
+`
+
+	if diff := cmp.Diff(b.String(), expected); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestFallbackURLLines(t *testing.T) {
+	t.Parallel()
+
+	input := `#EXTM3U
+#EXTINF:-1 tvg-id="channel-1",Channel 1
+http://127.0.0.1/stream_1
+http://127.0.0.2/stream_1
+
+http://127.0.0.3/stream_1
+#EXTINF:-1 tvg-id="channel-2",Channel 2
+http://127.0.0.1/stream_2
+`
+
+	playlist := &m3u.Playlist{}
+	if err := m3u.NewDecoder(strings.NewReader(input), m3u.WithFallbackGrouping()).Decode(playlist); err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+
+	expectedPlaylist := &m3u.Playlist{
+		Tracks: []m3u.Track{
+			{
+				Length: -1,
+				Name:   "Channel 1",
+				TVGID:  makePointer("channel-1"),
+				URL:    makeURL(t, "http://127.0.0.1/stream_1"),
+				AlternateURLs: []*url.URL{
+					makeURL(t, "http://127.0.0.2/stream_1"),
+					makeURL(t, "http://127.0.0.3/stream_1"),
+				},
+			},
+			{
+				Length: -1,
+				Name:   "Channel 2",
+				TVGID:  makePointer("channel-2"),
+				URL:    makeURL(t, "http://127.0.0.1/stream_2"),
+			},
+		},
+	}
+
+	if diff := cmp.Diff(playlist, expectedPlaylist); diff != "" {
+		t.Error(diff)
+	}
+
+	// Alternate URLs count as tracks
+	decoder := m3u.NewDecoder(strings.NewReader(input), m3u.WithFallbackGrouping(), m3u.WithLimits(m3u.Limits{MaxTracks: 2}))
+	expectedErr := m3u.LimitError{Limit: "tracks", Max: 2, LineNumber: 6}
+	if err := decoder.Decode(&m3u.Playlist{}); !errors.Is(err, expectedErr) {
+		t.Errorf("Expected %v, got: %v", expectedErr, err)
+	}
+
+	// Without grouping, URL lines must follow an #EXTINF directive block
+	if _, err := m3u.Unmarshal([]byte(input)); !errors.Is(err, m3u.ErrUnexpectedContent) {
+		t.Errorf("Expected ErrUnexpectedContent, got: %v", err)
+	}
+}
+
 func TestRoundTrip(t *testing.T) {
 	t.Parallel()
 
@@ -481,3 +959,644 @@ Unexpected content
 		t.Fatalf("Expected error message to contain unexpected content, got: %v", err)
 	}
 }
+
+func TestInvalidPlaylistErrorKinds(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		input    string
+		sentinel error
+		expected m3u.InvalidPlaylistError
+	}{
+		{
+			name:     "missing header",
+			input:    "#EXTINF:-1,Channel 1\nhttp://127.0.0.1/stream_1\n",
+			sentinel: m3u.ErrMissingHeader,
+			expected: m3u.InvalidPlaylistError{
+				Message:    "playlist must start with the `#EXTM3U` directive",
+				LineNumber: 1,
+				Line:       "#EXTINF:-1,Channel 1",
+				Kind:       m3u.ErrorKindMissingHeader,
+				Column:     1,
+			},
+		},
+		{
+			name:     "malformed header",
+			input:    "#EXTM3Uurl-tvg=\"http://127.0.0.1/epg.xml\"\n",
+			sentinel: m3u.ErrMalformedHeader,
+			expected: m3u.InvalidPlaylistError{
+				LineNumber: 1,
+				Line:       `#EXTM3Uurl-tvg="http://127.0.0.1/epg.xml"`,
+				Kind:       m3u.ErrorKindMalformedHeader,
+				Column:     8,
+			},
+		},
+		{
+			name:     "malformed length",
+			input:    "#EXTM3U\n#EXTINF:NotANumber,Channel 1\nhttp://127.0.0.1/stream_1\n",
+			sentinel: m3u.ErrMalformedEXTINF,
+			expected: m3u.InvalidPlaylistError{
+				LineNumber: 2,
+				Line:       "#EXTINF:NotANumber,Channel 1",
+				Kind:       m3u.ErrorKindMalformedEXTINF,
+				Column:     9,
+			},
+		},
+		{
+			name:     "length out of range",
+			input:    "#EXTM3U\n#EXTINF:1" + strings.Repeat("0", 400) + ",Channel 1\nhttp://127.0.0.1/stream_1\n",
+			sentinel: m3u.ErrMalformedEXTINF,
+			expected: m3u.InvalidPlaylistError{
+				LineNumber: 2,
+				Line:       "#EXTINF:1" + strings.Repeat("0", 400) + ",Channel 1",
+				Kind:       m3u.ErrorKindMalformedEXTINF,
+				Column:     9,
+			},
+		},
+		{
+			name:     "malformed attribute",
+			input:    "#EXTM3U\n#EXTINF:-1 tvg-id=\"channel-1\" group-title=\"Group 1,Channel 1\nhttp://127.0.0.1/stream_1\n",
+			sentinel: m3u.ErrMalformedEXTINF,
+			expected: m3u.InvalidPlaylistError{
+				LineNumber: 2,
+				Line:       `#EXTINF:-1 tvg-id="channel-1" group-title="Group 1,Channel 1`,
+				Kind:       m3u.ErrorKindMalformedEXTINF,
+				Column:     31,
+				Attribute:  "group-title",
+			},
+		},
+		{
+			name:     "orphan directive",
+			input:    "#EXTM3U\n#EXTVLCOPT:http-referrer=http://example.com/\n",
+			sentinel: m3u.ErrOrphanDirective,
+			expected: m3u.InvalidPlaylistError{
+				Message:    "`#EXTINF` directive must appear before any other directive",
+				LineNumber: 2,
+				Line:       "#EXTVLCOPT:http-referrer=http://example.com/",
+				Kind:       m3u.ErrorKindOrphanDirective,
+				Column:     1,
+			},
+		},
+		{
+			name:     "missing URL",
+			input:    "#EXTM3U\n#EXTINF:-1,Channel 1\n#EXTINF:-1,Channel 2\nhttp://127.0.0.1/stream_2\n",
+			sentinel: m3u.ErrMissingURL,
+			expected: m3u.InvalidPlaylistError{
+				Message:    "`#EXTINF` directive block must end with a URL",
+				LineNumber: 3,
+				Line:       "#EXTINF:-1,Channel 2",
+				Kind:       m3u.ErrorKindMissingURL,
+				Column:     1,
+			},
+		},
+		{
+			name:     "invalid URL",
+			input:    "#EXTM3U\n#EXTINF:-1,Channel 1\nhttp://127.0.0.1/stream_1%\n",
+			sentinel: m3u.ErrInvalidURL,
+			expected: m3u.InvalidPlaylistError{
+				LineNumber: 3,
+				Line:       "http://127.0.0.1/stream_1%",
+				Kind:       m3u.ErrorKindInvalidURL,
+				Column:     1,
+			},
+		},
+		{
+			name:     "invalid image URL",
+			input:    "#EXTM3U\n#EXTIMG:http://127.0.0.1/cover%.jpg\n",
+			sentinel: m3u.ErrInvalidURL,
+			expected: m3u.InvalidPlaylistError{
+				LineNumber: 2,
+				Line:       "#EXTIMG:http://127.0.0.1/cover%.jpg",
+				Kind:       m3u.ErrorKindInvalidURL,
+				Column:     9,
+			},
+		},
+		{
+			name:     "unexpected content",
+			input:    "#EXTM3U\nUnexpected content\n",
+			sentinel: m3u.ErrUnexpectedContent,
+			expected: m3u.InvalidPlaylistError{
+				Message:    "unexpected content",
+				LineNumber: 2,
+				Line:       "Unexpected content",
+				Kind:       m3u.ErrorKindUnexpectedContent,
+				Column:     1,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := m3u.Unmarshal([]byte(test.input))
+
+			var invErr m3u.InvalidPlaylistError
+			if !errors.As(err, &invErr) {
+				t.Fatalf("Expected an InvalidPlaylistError error, got: %v", err)
+			}
+
+			if !errors.Is(err, test.sentinel) {
+				t.Errorf("Expected error to match %v", test.sentinel)
+			}
+
+			if errors.Is(err, m3u.ErrUnexpectedContent) != (test.sentinel == m3u.ErrUnexpectedContent) {
+				t.Errorf("Expected error not to match %v", m3u.ErrUnexpectedContent)
+			}
+
+			// Messages that embed parser details are only checked through
+			// Error
+			if test.expected.Message == "" {
+				test.expected.Message = invErr.Message
+			}
+
+			if diff := cmp.Diff(invErr, test.expected); diff != "" {
+				t.Error(diff)
+			}
+
+			expectedError := fmt.Sprintf("invalid m3u playlist: line %d: `%s`: %s", invErr.LineNumber, invErr.Line, invErr.Message)
+			if err.Error() != expectedError {
+				t.Errorf("Expected error %q, got %q", expectedError, err.Error())
+			}
+		})
+	}
+}
+
+func TestDecodeInvalidURLAttributes(t *testing.T) {
+	t.Parallel()
+
+	input := `#EXTM3U url-tvg="http://127.0.0.1/epg%zz.xml" x-tvg-url="http://127.0.0.1/epg.xml"
+#EXTINF:-1 tvg-id="channel-1" tvg-logo="http://127.0.0.1/logos/%zz.png",Channel 1
+http://127.0.0.1/stream_1
+`
+
+	playlist := &m3u.Playlist{}
+	decoder := m3u.NewDecoder(strings.NewReader(input))
+	if err := decoder.Decode(playlist); err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+
+	expectedPlaylist := &m3u.Playlist{
+		XTVGURL: makeURL(t, "http://127.0.0.1/epg.xml"),
+		ExtraAttributes: map[string]string{
+			"url-tvg": "http://127.0.0.1/epg%zz.xml",
+		},
+		Tracks: []m3u.Track{
+			{
+				Length: -1,
+				Name:   "Channel 1",
+				TVGID:  makePointer("channel-1"),
+				URL:    makeURL(t, "http://127.0.0.1/stream_1"),
+				ExtraAttributes: map[string]string{
+					"tvg-logo": "http://127.0.0.1/logos/%zz.png",
+				},
+			},
+		},
+	}
+
+	if diff := cmp.Diff(playlist, expectedPlaylist); diff != "" {
+		t.Error(diff)
+	}
+
+	var warnings []string
+	for _, warning := range decoder.Warnings() {
+		warnings = append(warnings, fmt.Sprintf("%d %s=%s", warning.LineNumber, warning.Attribute, warning.Value))
+	}
+
+	expectedWarnings := []string{
+		"1 url-tvg=http://127.0.0.1/epg%zz.xml",
+		"2 tvg-logo=http://127.0.0.1/logos/%zz.png",
+	}
+
+	if diff := cmp.Diff(warnings, expectedWarnings); diff != "" {
+		t.Error(diff)
+	}
+
+	// The raw values survive a re-encode
+	data, err := m3u.Marshal(playlist, m3u.M3UPlus)
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+
+	for _, value := range []string{`url-tvg="http://127.0.0.1/epg%zz.xml"`, `tvg-logo="http://127.0.0.1/logos/%zz.png"`} {
+		if !strings.Contains(string(data), value) {
+			t.Errorf("Expected output to contain %s, got:\n%s", value, data)
+		}
+	}
+}
+
+func TestDecodeInvalidImage(t *testing.T) {
+	t.Parallel()
+
+	input := `#EXTM3U
+#EXTINF:215,Song 1
+#EXTIMG:http://127.0.0.1/cover 100%.jpg
+music/01.mp3
+`
+
+	playlist := &m3u.Playlist{}
+	decoder := m3u.NewDecoder(strings.NewReader(input))
+	if err := decoder.Decode(playlist); err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+
+	expectedTracks := []m3u.Track{
+		{
+			Length:          215,
+			Name:            "Song 1",
+			URL:             makeURL(t, "music/01.mp3"),
+			ExtraDirectives: []string{"#EXTIMG:http://127.0.0.1/cover 100%.jpg"},
+		},
+	}
+
+	if diff := cmp.Diff(playlist.Tracks, expectedTracks); diff != "" {
+		t.Error(diff)
+	}
+
+	warnings := decoder.Warnings()
+	if len(warnings) != 1 || warnings[0].LineNumber != 3 || warnings[0].Attribute != "#EXTIMG" || warnings[0].Value != "http://127.0.0.1/cover 100%.jpg" {
+		t.Errorf("Expected a warning about line 3, got: %v", warnings)
+	}
+}
+
+func TestDecodeAttributeQuoting(t *testing.T) {
+	t.Parallel()
+
+	input := `#EXTM3U url-tvg='http://127.0.0.1/epg.xml' tvg-shift=2
+#EXTINF:-1 tvg-id=channel-1 tvg-name='Channel \'1\'' tvg-language="English" group-title="Group \"1\"",Channel 1
+http://127.0.0.1/stream_1
+`
+
+	playlist, err := m3u.Unmarshal([]byte(input))
+	if err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+
+	expectedPlaylist := &m3u.Playlist{
+		TVGURL: makeURL(t, "http://127.0.0.1/epg.xml"),
+		ExtraAttributes: map[string]string{
+			"tvg-shift": "2",
+		},
+		AttributeQuoting: map[string]m3u.Quoting{
+			"url-tvg":   m3u.SingleQuoted,
+			"tvg-shift": m3u.Unquoted,
+		},
+		Tracks: []m3u.Track{
+			{
+				Length:      -1,
+				Name:        "Channel 1",
+				TVGID:       makePointer("channel-1"),
+				TVGName:     makePointer("Channel '1'"),
+				TVGLanguage: makePointer("English"),
+				GroupTitle:  makePointer(`Group "1"`),
+				URL:         makeURL(t, "http://127.0.0.1/stream_1"),
+				AttributeQuoting: map[string]m3u.Quoting{
+					"tvg-id":   m3u.Unquoted,
+					"tvg-name": m3u.SingleQuoted,
+				},
+			},
+		},
+	}
+
+	if diff := cmp.Diff(playlist, expectedPlaylist); diff != "" {
+		t.Error(diff)
+	}
+
+	var lossless strings.Builder
+	if err := m3u.NewEncoder(&lossless, m3u.WithLossless()).Encode(playlist, m3u.M3UPlus); err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+
+	expectedLossless := `#EXTM3U url-tvg='http://127.0.0.1/epg.xml' tvg-shift=2
+#EXTINF:-1 tvg-id=channel-1 tvg-name='Channel \'1\'' tvg-language="English" group-title="Group \"1\"",Channel 1
+http://127.0.0.1/stream_1
+`
+	if lossless.String() != expectedLossless {
+		t.Fatalf("Expected:\n%s\nGot:\n%s", expectedLossless, lossless.String())
+	}
+
+	data, err := m3u.Marshal(playlist, m3u.M3UPlus)
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+
+	expected := `#EXTM3U url-tvg="http://127.0.0.1/epg.xml" tvg-shift="2"
+#EXTINF:-1 tvg-id="channel-1" tvg-name="Channel '1'" tvg-language="English" group-title="Group \"1\"",Channel 1
+http://127.0.0.1/stream_1
+`
+	if string(data) != expected {
+		t.Fatalf("Expected:\n%s\nGot:\n%s", expected, string(data))
+	}
+}
+
+func TestDecodeLongLinesAndCRLF(t *testing.T) {
+	t.Parallel()
+
+	name := strings.Repeat("Channel ", 2000)
+
+	input := "#EXTM3U\r\n" +
+		`#EXTINF:-1 tvg-id="channel-1",` + name + "\r\n" +
+		"http://127.0.0.1/stream_1\r\n" +
+		"#EXTINF:10.5 tvg-name=Short,Short\r\n" +
+		"http://127.0.0.1/stream_2"
+
+	playlist, err := m3u.Unmarshal([]byte(input))
+	if err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+
+	expectedPlaylist := &m3u.Playlist{
+		Tracks: []m3u.Track{
+			{
+				Length: -1,
+				Name:   strings.TrimSpace(name),
+				TVGID:  makePointer("channel-1"),
+				URL:    makeURL(t, "http://127.0.0.1/stream_1"),
+			},
+			{
+				Length:           10.5,
+				Name:             "Short",
+				TVGName:          makePointer("Short"),
+				URL:              makeURL(t, "http://127.0.0.1/stream_2"),
+				AttributeQuoting: map[string]m3u.Quoting{"tvg-name": m3u.Unquoted},
+			},
+		},
+	}
+
+	if diff := cmp.Diff(playlist, expectedPlaylist); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestDecodeLongHeaderLines(t *testing.T) {
+	t.Parallel()
+
+	// Headers are scanned in linear time, however little of them are
+	// attributes
+	tests := []struct {
+		name   string
+		header string
+	}{
+		{name: "key characters", header: strings.Repeat("a", 1<<20)},
+		{name: "long key without value", header: strings.Repeat("a", 1<<20) + "= b"},
+		{name: "long key with unterminated value", header: strings.Repeat("a", 1<<20) + `="` + strings.Repeat("b", 1<<10)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			playlist, err := m3u.Unmarshal([]byte("#EXTM3U " + test.header + " tvg-shift=2\n"))
+			if err != nil {
+				t.Fatalf("Failed to unmarshal: %v", err)
+			}
+
+			if diff := cmp.Diff(playlist.ExtraAttributes, map[string]string{"tvg-shift": "2"}); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestDecodeProgress(t *testing.T) {
+	t.Parallel()
+
+	data := makeLargePlaylist(3)
+
+	var reports []m3u.Progress
+
+	decoder := m3u.NewDecoder(bytes.NewReader(data), m3u.WithProgress(func(p m3u.Progress) {
+		reports = append(reports, p)
+	}))
+
+	var playlist m3u.Playlist
+	if err := decoder.DecodeContext(context.Background(), &playlist); err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+
+	lineLengths := []int64{}
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		lineLengths = append(lineLengths, int64(len(line)))
+	}
+
+	bytesAfter := func(lines int) int64 {
+		var n int64
+		for _, length := range lineLengths[:lines] {
+			n += length
+		}
+
+		return n
+	}
+
+	expectedReports := []m3u.Progress{
+		{Bytes: bytesAfter(4), Lines: 4, Tracks: 1},
+		{Bytes: bytesAfter(7), Lines: 7, Tracks: 2},
+		{Bytes: bytesAfter(10), Lines: 10, Tracks: 3},
+		{Bytes: int64(len(data)), Lines: 11, Tracks: 3},
+	}
+
+	if diff := cmp.Diff(reports, expectedReports); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestDecodeContextCanceled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tracks := 0
+
+	decoder := m3u.NewDecoder(bytes.NewReader(makeLargePlaylist(10)), m3u.WithProgress(func(p m3u.Progress) {
+		tracks = p.Tracks
+		if p.Tracks == 2 {
+			cancel()
+		}
+	}))
+
+	var playlist m3u.Playlist
+	if err := decoder.DecodeContext(ctx, &playlist); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got: %v", err)
+	}
+
+	if tracks != 2 {
+		t.Errorf("Expected decoding to stop after 2 tracks, got %d", tracks)
+	}
+
+	var compact m3u.CompactPlaylist
+	if err := m3u.NewDecoder(bytes.NewReader(makeLargePlaylist(10))).DecodeCompactContext(ctx, &compact); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got: %v", err)
+	}
+}
+
+// endlessReader returns an endless stream of its byte.
+type endlessReader byte
+
+func (r endlessReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(r)
+	}
+
+	return len(p), nil
+}
+
+func TestDecodeLimits(t *testing.T) {
+	t.Parallel()
+
+	input := `#EXTM3U url-tvg="http://127.0.0.1/epg.xml"
+#EXTINF:-1 tvg-id="channel-1" tvg-name="Channel 1",Channel 1
+#EXTVLCOPT:http-referrer=http://example.com/
+#EXTVLCOPT:http-user-agent=Mozilla/5.0
+http://127.0.0.1/stream_1
+#EXTINF:-1,Channel 2
+http://127.0.0.1/stream_2
+`
+
+	tests := []struct {
+		name     string
+		input    io.Reader
+		limits   m3u.Limits
+		expected m3u.LimitError
+	}{
+		{
+			name:     "line length",
+			input:    strings.NewReader(input),
+			limits:   m3u.Limits{MaxLineLength: 50},
+			expected: m3u.LimitError{Limit: "line length", Max: 50, LineNumber: 2},
+		},
+		{
+			name:     "endless line",
+			input:    io.MultiReader(strings.NewReader("#EXTM3U\n"), endlessReader('a')),
+			limits:   m3u.Limits{MaxLineLength: 1 << 20},
+			expected: m3u.LimitError{Limit: "line length", Max: 1 << 20, LineNumber: 2},
+		},
+		{
+			name:     "tracks",
+			input:    strings.NewReader(input),
+			limits:   m3u.Limits{MaxTracks: 1},
+			expected: m3u.LimitError{Limit: "tracks", Max: 1, LineNumber: 6},
+		},
+		{
+			name:     "attributes per line",
+			input:    strings.NewReader(input),
+			limits:   m3u.Limits{MaxAttributes: 1},
+			expected: m3u.LimitError{Limit: "attributes per line", Max: 1, LineNumber: 2},
+		},
+		{
+			// The lexer stops before the malformed end of the line
+			name:     "attributes before a malformed end",
+			input:    strings.NewReader("#EXTM3U\n#EXTINF:-1" + strings.Repeat(` a="1"`, 1<<16) + ` b="Channel 1\n"`),
+			limits:   m3u.Limits{MaxAttributes: 2},
+			expected: m3u.LimitError{Limit: "attributes per line", Max: 2, LineNumber: 2},
+		},
+		{
+			name:     "header attributes",
+			input:    strings.NewReader("#EXTM3U" + strings.Repeat(` a="1"`, 1<<16) + "\n"),
+			limits:   m3u.Limits{MaxAttributes: 2},
+			expected: m3u.LimitError{Limit: "attributes per line", Max: 2, LineNumber: 1},
+		},
+		{
+			name:     "directives per track",
+			input:    strings.NewReader(input),
+			limits:   m3u.Limits{MaxDirectives: 1},
+			expected: m3u.LimitError{Limit: "directives per track", Max: 1, LineNumber: 4},
+		},
+		{
+			name:     "playlist size",
+			input:    strings.NewReader(input),
+			limits:   m3u.Limits{MaxBytes: 100},
+			expected: m3u.LimitError{Limit: "playlist size", Max: 100, LineNumber: 2},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var playlist m3u.Playlist
+
+			err := m3u.NewDecoder(test.input, m3u.WithLimits(test.limits)).Decode(&playlist)
+
+			var limitErr m3u.LimitError
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("Expected a LimitError, got: %v", err)
+			}
+
+			if diff := cmp.Diff(limitErr, test.expected); diff != "" {
+				t.Error(diff)
+			}
+
+			if errors.As(err, new(m3u.InvalidPlaylistError)) {
+				t.Error("Expected LimitError not to be an InvalidPlaylistError")
+			}
+		})
+	}
+
+	var playlist m3u.Playlist
+
+	limits := m3u.Limits{MaxLineLength: 62, MaxTracks: 2, MaxAttributes: 2, MaxDirectives: 2, MaxBytes: int64(len(input))}
+	if err := m3u.NewDecoder(strings.NewReader(input), m3u.WithLimits(limits)).Decode(&playlist); err != nil {
+		t.Fatalf("Expected playlist within limits to decode, got: %v", err)
+	}
+}
+
+// makeLargePlaylist returns the M3U Plus encoding of a playlist with n tracks.
+func makeLargePlaylist(n int) []byte {
+	var b strings.Builder
+
+	b.WriteString(`#EXTM3U url-tvg="http://127.0.0.1/epg.xml" x-tvg-url="http://127.0.0.1/epg.xml"` + "\n")
+
+	for i := range n {
+		fmt.Fprintf(&b, `#EXTINF:-1 tvg-id="channel-%d" tvg-name="Channel %d" tvg-language="English" `+
+			`tvg-logo="http://127.0.0.1/logos/live_stream_%d.png" group-title="Group %d" tvg-chno="%d",Channel %d`+"\n",
+			i, i, i, i%10, i, i)
+		b.WriteString("#EXTVLCOPT:http-referrer=http://example.com/\n")
+		fmt.Fprintf(&b, "http://127.0.0.1/stream_%d\n", i)
+	}
+
+	return []byte(b.String())
+}
+
+func BenchmarkDecode(b *testing.B) {
+	const tracks = 1000
+
+	data := makeLargePlaylist(tracks)
+
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+
+	for b.Loop() {
+		if _, err := m3u.Unmarshal(data); err != nil {
+			b.Fatalf("Failed to unmarshal: %v", err)
+		}
+	}
+
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*tracks), "ns/track")
+}
+
+func BenchmarkDecodeLongHeader(b *testing.B) {
+	data := []byte("#EXTM3U " + strings.Repeat("a", 100_000) + "\n")
+
+	b.SetBytes(int64(len(data)))
+
+	for b.Loop() {
+		if _, err := m3u.Unmarshal(data); err != nil {
+			b.Fatalf("Failed to unmarshal: %v", err)
+		}
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	playlist, err := m3u.Unmarshal(makeLargePlaylist(1000))
+	if err != nil {
+		b.Fatalf("Failed to unmarshal: %v", err)
+	}
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		if _, err := m3u.Marshal(playlist, m3u.M3UPlus); err != nil {
+			b.Fatalf("Failed to marshal: %v", err)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package m3u
+
+import (
+	"errors"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PathReplacement replaces the directory Old, and the paths inside it, with
+// New, such as the old location of a music library with its new one.
+type PathReplacement struct {
+	Old string
+	New string
+}
+
+// RebaseOptions configures Rebase.
+type RebaseOptions struct {
+	// From is the directory relative track paths are relative to, usually
+	// that of the playlist. It defaults to the working directory.
+	From string
+	// To is the directory rewritten relative paths are relative to, usually
+	// the new directory of the playlist. It defaults to From.
+	To string
+	// Replacements are applied to the absolute paths of tracks; the first one
+	// that matches a path is used. Backslashes and slashes are equivalent, and
+	// only whole directories match.
+	Replacements []PathReplacement
+	// Absolute makes Rebase write absolute paths rather than paths relative to
+	// To.
+	Absolute bool
+	// Separator separates the directories of rewritten paths. It defaults to
+	// '/'; use '\\' for Windows players that expect backslashes.
+	Separator byte
+	// CheckExists makes Rebase report the tracks whose files do not exist.
+	CheckExists bool
+}
+
+// RebaseReport describes the tracks rewritten by Rebase.
+type RebaseReport struct {
+	// Rebased is the number of tracks whose URL is a file.
+	Rebased int
+	// Missing holds the indexes of the tracks whose files do not exist, when
+	// RebaseOptions.CheckExists is set.
+	Missing []int
+}
+
+// Rebase rewrites the locations of the tracks that are files, given as file
+// paths or file URLs, so that they remain valid when the playlist or the files
+// are moved. Locations are resolved against From, their prefixes replaced, and
+// written as file paths relative to To. Other URLs are left as is. The
+// alternate URLs of tracks and the images of the playlist and tracks are
+// rewritten alike; only the files of track URLs are checked.
+//
+// File paths are read and written as is: decode the playlist with
+// WithFilePaths, and encode it with WithFilePathOutput.
+func (p *Playlist) Rebase(opts RebaseOptions) RebaseReport {
+	from := dirURL(opts.From)
+
+	to := from
+	if opts.To != "" {
+		to = dirURL(opts.To)
+	}
+
+	var report RebaseReport
+
+	p.Image, _ = rebaseLocation(p.Image, from, to, opts)
+
+	for i := range p.Tracks {
+		track := &p.Tracks[i]
+		track.Image, _ = rebaseLocation(track.Image, from, to, opts)
+
+		for j := range track.AlternateURLs {
+			track.AlternateURLs[j], _ = rebaseLocation(track.AlternateURLs[j], from, to, opts)
+		}
+
+		var name string
+		if track.URL, name = rebaseLocation(track.URL, from, to, opts); name == "" {
+			continue
+		}
+
+		if opts.CheckExists {
+			if _, err := os.Stat(filepath.FromSlash(name)); errors.Is(err, fs.ErrNotExist) {
+				report.Missing = append(report.Missing, i)
+			}
+		}
+
+		report.Rebased++
+	}
+
+	return report
+}
+
+// rebaseLocation returns the location u rewritten as done by Rebase, along
+// with the absolute, slash-separated path of its file. Locations that are not
+// files are returned as is, with an empty path.
+func rebaseLocation(u, from, to *url.URL, opts RebaseOptions) (*url.URL, string) {
+	if u == nil || (u.Scheme != "" && u.Scheme != "file") {
+		return u, ""
+	}
+
+	resolved, err := ResolveLocation(locationString(u), from)
+	if err != nil || resolved.Scheme != "file" {
+		return u, ""
+	}
+
+	name := replacePath(filePath(resolved), opts.Replacements)
+
+	location, ok := "", false
+	if !opts.Absolute {
+		location, ok = RelativeLocation(fileURL(name), to)
+	}
+
+	if !ok {
+		location = name
+	}
+
+	if opts.Separator != 0 && opts.Separator != '/' {
+		location = strings.ReplaceAll(location, "/", string(opts.Separator))
+	}
+
+	return &url.URL{Path: location}, name
+}
+
+// replacePath applies the first of replacements matching the slash-separated
+// path name.
+func replacePath(name string, replacements []PathReplacement) string {
+	for _, r := range replacements {
+		old := strings.TrimSuffix(strings.ReplaceAll(r.Old, `\`, "/"), "/")
+
+		rest, ok := strings.CutPrefix(name, old)
+		if !ok || (rest != "" && rest[0] != '/') {
+			continue
+		}
+
+		return strings.TrimSuffix(strings.ReplaceAll(r.New, `\`, "/"), "/") + rest
+	}
+
+	return name
+}
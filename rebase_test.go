@@ -0,0 +1,166 @@
+package m3u_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sherif-fanous/m3u"
+)
+
+const rebaseInput = `#EXTM3U
+#EXTIMG:covers/front.jpg
+#EXTINF:215,Artist - Song #1
+#EXTIMG:../Album/cover.jpg
+../Album/01 Song #1.mp3
+#EXTINF:180,Artist - Song 2
+C:\Music\Album\02.mp3
+#EXTINF:-1,Radio
+http://127.0.0.1/radio.mp3
+#EXTINF:200,Artist - Song 3
+file:///music/Album/03%20Song.mp3
+`
+
+func TestRebase(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		opts     m3u.RebaseOptions
+		expected string
+	}{
+		{
+			name: "move playlist",
+			opts: m3u.RebaseOptions{From: "/music/playlists", To: "/music/playlists/archive"},
+			expected: `#EXTM3U
+#EXTIMG:../covers/front.jpg
+#EXTINF:215,Artist - Song #1
+#EXTIMG:../../Album/cover.jpg
+../../Album/01 Song #1.mp3
+#EXTINF:180,Artist - Song 2
+C:/Music/Album/02.mp3
+#EXTINF:-1,Radio
+http://127.0.0.1/radio.mp3
+#EXTINF:200,Artist - Song 3
+../../Album/03 Song.mp3
+`,
+		},
+		{
+			name: "move library",
+			opts: m3u.RebaseOptions{
+				From: "/music/playlists",
+				Replacements: []m3u.PathReplacement{
+					{Old: "/music/Album", New: `D:\Library\Album`},
+					{Old: `C:\Music\`, New: `D:\Library`},
+				},
+				Absolute:  true,
+				Separator: '\\',
+			},
+			expected: `#EXTM3U
+#EXTIMG:\music\playlists\covers\front.jpg
+#EXTINF:215,Artist - Song #1
+#EXTIMG:D:\Library\Album\cover.jpg
+D:\Library\Album\01 Song #1.mp3
+#EXTINF:180,Artist - Song 2
+D:\Library\Album\02.mp3
+#EXTINF:-1,Radio
+http://127.0.0.1/radio.mp3
+#EXTINF:200,Artist - Song 3
+D:\Library\Album\03 Song.mp3
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			playlist := decodeFilePaths(t, rebaseInput)
+
+			report := playlist.Rebase(test.opts)
+			if report.Rebased != 3 {
+				t.Errorf("Expected 3 rebased tracks, got %d", report.Rebased)
+			}
+
+			var b bytes.Buffer
+			if err := m3u.NewEncoder(&b, m3u.WithFilePathOutput()).Encode(playlist, m3u.M3U); err != nil {
+				t.Fatalf("Failed to encode: %v", err)
+			}
+
+			if diff := cmp.Diff(b.String(), test.expected); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestRebaseCheckExists(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	if err := os.Mkdir(filepath.Join(dir, "Album"), 0o755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "Album", "01 Song #1.mp3"), nil, 0o644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	playlist := decodeFilePaths(t, "#EXTM3U\n#EXTINF:-1,Song 1\nAlbum/01 Song #1.mp3\n#EXTINF:-1,Song 2\nAlbum/02.mp3\n")
+
+	report := playlist.Rebase(m3u.RebaseOptions{From: dir, To: filepath.Join(dir, "Album"), CheckExists: true})
+
+	expected := m3u.RebaseReport{Rebased: 2, Missing: []int{1}}
+	if diff := cmp.Diff(report, expected); diff != "" {
+		t.Error(diff)
+	}
+
+	if location := playlist.Tracks[0].URL.Path; location != "01 Song #1.mp3" {
+		t.Errorf("Expected 01 Song #1.mp3, got %q", location)
+	}
+}
+
+func TestRebaseAlternateURLs(t *testing.T) {
+	t.Parallel()
+
+	input := `#EXTM3U
+#EXTINF:215,Artist - Song 1
+../Album/01.mp3
+#EXTINF:215,Artist - Song 1
+../Backup/01.mp3
+#EXTINF:215,Artist - Song 1
+http://127.0.0.1/01.mp3
+`
+
+	playlist := &m3u.Playlist{}
+	if err := m3u.NewDecoder(strings.NewReader(input), m3u.WithFilePaths(), m3u.WithFallbackGrouping()).Decode(playlist); err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+
+	playlist.Rebase(m3u.RebaseOptions{From: "/music/playlists", To: "/music"})
+
+	var urls []string
+	for _, u := range playlist.Tracks[0].AlternateURLs {
+		urls = append(urls, u.String())
+	}
+
+	if diff := cmp.Diff(urls, []string{"Backup/01.mp3", "http://127.0.0.1/01.mp3"}); diff != "" {
+		t.Error(diff)
+	}
+}
+
+// decodeFilePaths decodes the playlist input, keeping its file paths as is.
+func decodeFilePaths(t *testing.T, input string) *m3u.Playlist {
+	t.Helper()
+
+	playlist := &m3u.Playlist{}
+	if err := m3u.NewDecoder(strings.NewReader(input), m3u.WithFilePaths()).Decode(playlist); err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+
+	return playlist
+}
@@ -0,0 +1,249 @@
+package m3u
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Redacted replaces credentials masked by RedactURL, RedactLine and
+// Playlist.Redact.
+const Redacted = "REDACTED"
+
+// sensitiveParams lists the query parameters, in lower case, whose values are
+// masked.
+var sensitiveParams = map[string]bool{
+	"username":     true,
+	"user":         true,
+	"password":     true,
+	"pass":         true,
+	"passwd":       true,
+	"pwd":          true,
+	"token":        true,
+	"access_token": true,
+	"auth":         true,
+	"key":          true,
+	"api_key":      true,
+	"apikey":       true,
+	"secret":       true,
+	"signature":    true,
+	"sig":          true,
+}
+
+// streamKinds lists the path segments that precede the credentials of Xtream
+// Codes stream URLs.
+var streamKinds = map[string]bool{
+	"live":   true,
+	"movie":  true,
+	"series": true,
+}
+
+var urlRegex = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^\s"'<>]+`)
+
+// RedactURL returns a copy of u with its credentials masked. Credentials are
+// looked for in the user information, in the values of query parameters such
+// as password and token, and in the path of Xtream Codes stream URLs, such as
+// /live/username/password/123.ts.
+func RedactURL(u *url.URL) *url.URL {
+	if u == nil {
+		return nil
+	}
+
+	redacted := *u
+
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			redacted.User = url.UserPassword(Redacted, Redacted)
+		} else {
+			redacted.User = url.User(Redacted)
+		}
+	}
+
+	redacted.Path = redactPath(u.Path)
+	if u.RawPath != "" {
+		redacted.RawPath = redactPath(u.RawPath)
+	}
+
+	redacted.RawQuery = redactQuery(u.RawQuery)
+
+	return &redacted
+}
+
+// RedactLine masks the credentials of every URL found in line, as done by
+// RedactURL. The rest of the line is left unchanged.
+func RedactLine(line string) string {
+	return urlRegex.ReplaceAllStringFunc(line, redactURLString)
+}
+
+// Redact masks the credentials of the URLs of the playlist and its tracks, as
+// done by RedactURL, and of the URLs found in attribute values and directives,
+// as done by RedactLine. It returns the number of values that were changed.
+func (p *Playlist) Redact() int {
+	count := 0
+
+	redactURLField := func(u **url.URL) {
+		if *u == nil {
+			return
+		}
+
+		redacted := RedactURL(*u)
+		if redacted.String() != (*u).String() {
+			*u = redacted
+			count++
+		}
+	}
+
+	redactString := func(s *string) {
+		if redacted := RedactLine(*s); redacted != *s {
+			*s = redacted
+			count++
+		}
+	}
+
+	redactAttributes := func(attributes map[string]string) {
+		for key, value := range attributes {
+			redactString(&value)
+			attributes[key] = value
+		}
+	}
+
+	redactURLField(&p.TVGURL)
+	redactURLField(&p.XTVGURL)
+	redactURLField(&p.Image)
+	redactAttributes(p.ExtraAttributes)
+
+	for i := range p.Tracks {
+		track := &p.Tracks[i]
+
+		redactURLField(&track.URL)
+		redactURLField(&track.TVGLogo)
+		redactURLField(&track.Image)
+		redactAttributes(track.ExtraAttributes)
+
+		for j := range track.AlternateURLs {
+			redactURLField(&track.AlternateURLs[j])
+		}
+
+		for j := range track.ExtraDirectives {
+			redactString(&track.ExtraDirectives[j])
+		}
+	}
+
+	return count
+}
+
+// redactURLString masks the credentials of the URL s. It works on the text of
+// the URL rather than its parsed form, so that URLs url.Parse rejects are
+// redacted too and the rest of the text is preserved as is.
+func redactURLString(s string) string {
+	scheme, rest, ok := strings.Cut(s, "://")
+	if !ok {
+		return s
+	}
+
+	rest, fragment, hasFragment := strings.Cut(rest, "#")
+	rest, query, hasQuery := strings.Cut(rest, "?")
+
+	authority, path := rest, ""
+	if i := strings.IndexByte(rest, '/'); i >= 0 {
+		authority, path = rest[:i], rest[i:]
+	}
+
+	if i := strings.LastIndexByte(authority, '@'); i >= 0 {
+		userinfo := Redacted
+		if strings.Contains(authority[:i], ":") {
+			userinfo += ":" + Redacted
+		}
+
+		authority = userinfo + authority[i:]
+	}
+
+	var b strings.Builder
+
+	b.WriteString(scheme + "://" + authority + redactPath(path))
+
+	if hasQuery {
+		b.WriteString("?" + redactQuery(query))
+	}
+
+	if hasFragment {
+		b.WriteString("#" + fragment)
+	}
+
+	return b.String()
+}
+
+// redactPath masks the credentials of Xtream Codes stream paths, which come in
+// the /kind/username/password/id.ext, /username/password/id and
+// /timeshift/username/password/duration/start/id.ext forms.
+func redactPath(path string) string {
+	segments := strings.Split(path, "/")
+
+	for i, segment := range segments {
+		if segment == "timeshift" && i+2 < len(segments) {
+			maskSegments(segments, i+1)
+			return strings.Join(segments, "/")
+		}
+	}
+
+	n := len(segments)
+	if n < 4 || !isStreamID(segments[n-1]) {
+		return path
+	}
+
+	// Without a stream kind only the short /username/password/id form, without
+	// an extension, is accepted, as other paths ending in a number are too
+	// common
+	if streamKinds[segments[n-4]] || (n == 4 && segments[0] == "" && !strings.Contains(segments[3], ".")) {
+		maskSegments(segments, n-3)
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// maskSegments masks the username and password segments starting at i.
+func maskSegments(segments []string, i int) {
+	for j := i; j < i+2; j++ {
+		if segments[j] != "" {
+			segments[j] = Redacted
+		}
+	}
+}
+
+// isStreamID reports whether the file name is a numeric stream ID with an
+// optional extension.
+func isStreamID(file string) bool {
+	id, _, _ := strings.Cut(file, ".")
+	_, err := strconv.ParseUint(id, 10, 64)
+
+	return err == nil
+}
+
+// redactQuery masks the values of sensitive parameters of the query, keeping
+// the order and encoding of the other parameters.
+func redactQuery(query string) string {
+	if query == "" {
+		return query
+	}
+
+	params := strings.Split(query, "&")
+
+	for i, param := range params {
+		key, _, ok := strings.Cut(param, "=")
+		if !ok {
+			continue
+		}
+
+		unescaped, err := url.QueryUnescape(key)
+		if err != nil {
+			unescaped = key
+		}
+
+		if sensitiveParams[strings.ToLower(unescaped)] {
+			params[i] = key + "=" + Redacted
+		}
+	}
+
+	return strings.Join(params, "&")
+}
@@ -0,0 +1,419 @@
+package m3u
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ActionType identifies what an Action does to a matching track.
+type ActionType string
+
+const (
+	// ActionSet sets a field to a value.
+	ActionSet ActionType = "set"
+	// ActionDelete removes a field.
+	ActionDelete ActionType = "delete"
+	// ActionRename moves the value of a field to another field.
+	ActionRename ActionType = "rename"
+	// ActionAddDirective appends a directive to the track's extra directives.
+	ActionAddDirective ActionType = "add-directive"
+	// ActionDrop removes the track from the playlist.
+	ActionDrop ActionType = "drop"
+)
+
+// Rule transforms the tracks that match all of its conditions.
+//
+// Fields are named after their M3U attributes: "tvg-id", "tvg-name",
+// "tvg-language", "tvg-logo" and "group-title", plus "name", "url" and
+// "length" for the track name, URL and length. Any other field name refers to
+// a key of the track's extra attributes.
+type Rule struct {
+	Name    string      `json:"name,omitempty" yaml:"name,omitempty"`
+	Match   []Condition `json:"match,omitempty" yaml:"match,omitempty"`
+	Actions []Action    `json:"actions" yaml:"actions"`
+}
+
+// Condition matches a track field against a regular expression. Missing fields
+// are matched as the empty string.
+type Condition struct {
+	Field   string `json:"field" yaml:"field"`
+	Pattern string `json:"pattern" yaml:"pattern"`
+	Negate  bool   `json:"negate,omitempty" yaml:"negate,omitempty"`
+}
+
+// Action modifies a track matched by a Rule.
+//
+// Values may reference the capture groups of the rule's conditions: ${name}
+// expands to a named group of any condition, and $1, $2, ... to the numbered
+// groups of the first condition. Use $$ for a literal dollar sign.
+type Action struct {
+	Type  ActionType `json:"type" yaml:"type"`
+	Field string     `json:"field,omitempty" yaml:"field,omitempty"`
+	Value string     `json:"value,omitempty" yaml:"value,omitempty"`
+	To    string     `json:"to,omitempty" yaml:"to,omitempty"`
+}
+
+// RuleSet is a compiled, ordered list of rules.
+type RuleSet struct {
+	rules []compiledRule
+}
+
+type compiledRule struct {
+	Rule
+	patterns []*regexp.Regexp
+}
+
+// CompileRules validates rules and compiles their patterns.
+func CompileRules(rules []Rule) (*RuleSet, error) {
+	rs := &RuleSet{}
+
+	for i, rule := range rules {
+		cr := compiledRule{Rule: rule}
+
+		for _, cond := range rule.Match {
+			if cond.Field == "" {
+				return nil, ruleError(i, rule, "condition is missing a field")
+			}
+
+			re, err := regexp.Compile(cond.Pattern)
+			if err != nil {
+				return nil, ruleError(i, rule, fmt.Sprintf("invalid pattern: %v", err))
+			}
+
+			cr.patterns = append(cr.patterns, re)
+		}
+
+		if len(rule.Actions) == 0 {
+			return nil, ruleError(i, rule, "rule has no actions")
+		}
+
+		for _, action := range rule.Actions {
+			if err := validateAction(action); err != nil {
+				return nil, ruleError(i, rule, err.Error())
+			}
+		}
+
+		rs.rules = append(rs.rules, cr)
+	}
+
+	return rs, nil
+}
+
+// LoadRules reads a JSON array of rules from r and compiles them.
+func LoadRules(r io.Reader) (*RuleSet, error) {
+	var rules []Rule
+
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&rules); err != nil {
+		return nil, fmt.Errorf("error reading rules: %w", err)
+	}
+
+	return CompileRules(rules)
+}
+
+// LoadRulesYAML reads a YAML sequence of rules from r and compiles them.
+func LoadRulesYAML(r io.Reader) (*RuleSet, error) {
+	var rules []Rule
+
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+
+	if err := decoder.Decode(&rules); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("error reading rules: %w", err)
+	}
+
+	return CompileRules(rules)
+}
+
+// LoadRulesFile reads and compiles the rules file at path. Files with a .yaml
+// or .yml extension are read as YAML, and other files as JSON.
+func LoadRulesFile(path string) (*RuleSet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return LoadRulesYAML(f)
+	default:
+		return LoadRules(f)
+	}
+}
+
+// Apply runs the rules, in order, against every track of the playlist, and
+// removes the tracks that were dropped. On error, the playlist is left
+// unchanged.
+func (rs *RuleSet) Apply(playlist *Playlist) error {
+	tracks := make([]Track, 0, len(playlist.Tracks))
+
+	for i := range playlist.Tracks {
+		// Work on a copy, as the actions update the extra attributes in place
+		track := playlist.Tracks[i]
+		track.ExtraAttributes = maps.Clone(track.ExtraAttributes)
+		track.ExtraDirectives = slices.Clip(track.ExtraDirectives)
+
+		keep, err := rs.ApplyTrack(&track)
+		if err != nil {
+			return fmt.Errorf("track %d: %w", i+1, err)
+		}
+
+		if keep {
+			tracks = append(tracks, track)
+		}
+	}
+
+	playlist.Tracks = tracks
+
+	return nil
+}
+
+// ApplyTrack runs the rules, in order, against track. It reports whether the
+// track should be kept; once a rule drops a track, no further rules run.
+func (rs *RuleSet) ApplyTrack(track *Track) (bool, error) {
+	for i, rule := range rs.rules {
+		captures, ok := rule.match(track)
+		if !ok {
+			continue
+		}
+
+		for _, action := range rule.Actions {
+			if action.Type == ActionDrop {
+				return false, nil
+			}
+
+			if err := applyAction(track, action, captures); err != nil {
+				return false, ruleError(i, rule.Rule, err.Error())
+			}
+		}
+	}
+
+	return true, nil
+}
+
+// match reports whether track satisfies every condition of the rule, and
+// returns the captures of the matching conditions.
+func (cr compiledRule) match(track *Track) (map[string]string, bool) {
+	captures := make(map[string]string)
+
+	for i, cond := range cr.Match {
+		value, _ := trackField(track, cond.Field)
+		re := cr.patterns[i]
+
+		submatches := re.FindStringSubmatch(value)
+		if (submatches != nil) == cond.Negate {
+			return nil, false
+		}
+
+		for j, name := range re.SubexpNames() {
+			if j >= len(submatches) {
+				break
+			}
+
+			if name != "" {
+				captures[name] = submatches[j]
+			}
+
+			if i == 0 {
+				captures[strconv.Itoa(j)] = submatches[j]
+			}
+		}
+	}
+
+	return captures, true
+}
+
+func validateAction(action Action) error {
+	switch action.Type {
+	case ActionSet:
+		if action.Field == "" {
+			return errors.New("set action is missing a field")
+		}
+	case ActionDelete:
+		if action.Field == "" || action.Field == "length" {
+			return fmt.Errorf("cannot delete field %q", action.Field)
+		}
+	case ActionRename:
+		if action.Field == "" || action.To == "" {
+			return errors.New("rename action requires a field and a target")
+		}
+
+		if action.Field == "length" || action.To == "length" {
+			return errors.New("cannot rename the length field")
+		}
+	case ActionAddDirective:
+		if !strings.HasPrefix(action.Value, "#") {
+			return fmt.Errorf("directive %q must start with `#`", action.Value)
+		}
+	case ActionDrop:
+	default:
+		return fmt.Errorf("unknown action type %q", action.Type)
+	}
+
+	return nil
+}
+
+func applyAction(track *Track, action Action, captures map[string]string) error {
+	expand := func(s string) string {
+		return os.Expand(s, func(key string) string {
+			if key == "$" {
+				return "$"
+			}
+
+			return captures[key]
+		})
+	}
+
+	switch action.Type {
+	case ActionSet:
+		return setTrackField(track, action.Field, expand(action.Value))
+	case ActionDelete:
+		deleteTrackField(track, action.Field)
+	case ActionRename:
+		value, ok := trackField(track, action.Field)
+		if !ok {
+			return nil
+		}
+
+		deleteTrackField(track, action.Field)
+
+		return setTrackField(track, action.To, value)
+	case ActionAddDirective:
+		track.ExtraDirectives = append(track.ExtraDirectives, expand(action.Value))
+	}
+
+	return nil
+}
+
+func ruleError(index int, rule Rule, message string) error {
+	if rule.Name != "" {
+		return fmt.Errorf("rule %d (%s): %s", index+1, rule.Name, message)
+	}
+
+	return fmt.Errorf("rule %d: %s", index+1, message)
+}
+
+// trackField returns the value of the named track field, and whether the
+// field is set.
+func trackField(track *Track, field string) (string, bool) {
+	stringValue := func(s *string) (string, bool) {
+		if s == nil {
+			return "", false
+		}
+
+		return *s, true
+	}
+
+	urlValue := func(u *url.URL) (string, bool) {
+		if u == nil {
+			return "", false
+		}
+
+		return u.String(), true
+	}
+
+	switch field {
+	case "name":
+		return track.Name, true
+	case "length":
+		return strconv.FormatFloat(track.Length, 'f', -1, 64), true
+	case "url":
+		return urlValue(track.URL)
+	case "tvg-id":
+		return stringValue(track.TVGID)
+	case "tvg-name":
+		return stringValue(track.TVGName)
+	case "tvg-language":
+		return stringValue(track.TVGLanguage)
+	case "tvg-logo":
+		return urlValue(track.TVGLogo)
+	case "group-title":
+		return stringValue(track.GroupTitle)
+	default:
+		value, ok := track.ExtraAttributes[field]
+		return value, ok
+	}
+}
+
+// setTrackField sets the named track field to value.
+func setTrackField(track *Track, field string, value string) error {
+	switch field {
+	case "name":
+		track.Name = value
+	case "length":
+		length, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid length %q", value)
+		}
+
+		track.Length = length
+	case "url", "tvg-logo":
+		u, err := parseURL(value)
+		if err != nil {
+			return fmt.Errorf("invalid URL %q: %v", value, err)
+		}
+
+		if field == "url" {
+			track.URL = u
+		} else {
+			track.TVGLogo = u
+		}
+	case "tvg-id":
+		track.TVGID = &value
+	case "tvg-name":
+		track.TVGName = &value
+	case "tvg-language":
+		track.TVGLanguage = &value
+	case "group-title":
+		track.GroupTitle = &value
+	default:
+		if track.ExtraAttributes == nil {
+			track.ExtraAttributes = make(map[string]string)
+		}
+		track.ExtraAttributes[field] = value
+	}
+
+	return nil
+}
+
+// deleteTrackField clears the named track field. The length field cannot be
+// cleared and is left unchanged.
+func deleteTrackField(track *Track, field string) {
+	switch field {
+	case "name":
+		track.Name = ""
+	case "url":
+		track.URL = nil
+	case "tvg-id":
+		track.TVGID = nil
+	case "tvg-name":
+		track.TVGName = nil
+	case "tvg-language":
+		track.TVGLanguage = nil
+	case "tvg-logo":
+		track.TVGLogo = nil
+	case "group-title":
+		track.GroupTitle = nil
+	default:
+		delete(track.ExtraAttributes, field)
+
+		if len(track.ExtraAttributes) == 0 {
+			track.ExtraAttributes = nil
+		}
+	}
+}
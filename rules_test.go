@@ -0,0 +1,233 @@
+package m3u_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sherif-fanous/m3u"
+)
+
+func TestRuleSetApply(t *testing.T) {
+	t.Parallel()
+
+	rules := `[
+	{
+		"name": "strip country prefix",
+		"match": [{"field": "name", "pattern": "^\\|(?P<country>[A-Z]{2})\\|\\s*(.*)$"}],
+		"actions": [
+			{"type": "set", "field": "name", "value": "$2"},
+			{"type": "set", "field": "tvg-country", "value": "${country}"}
+		]
+	},
+	{
+		"match": [{"field": "group-title", "pattern": "(?i)adult"}],
+		"actions": [{"type": "drop"}]
+	},
+	{
+		"match": [{"field": "tvg_id", "pattern": "."}],
+		"actions": [{"type": "rename", "field": "tvg_id", "to": "tvg-id"}]
+	},
+	{
+		"match": [{"field": "tvg-logo", "pattern": ".", "negate": true}],
+		"actions": [
+			{"type": "set", "field": "tvg-logo", "value": "http://127.0.0.1/logos/default.png"},
+			{"type": "add-directive", "value": "#EXTVLCOPT:http-user-agent=VLC"}
+		]
+	}
+]`
+
+	ruleSet, err := m3u.LoadRules(strings.NewReader(rules))
+	if err != nil {
+		t.Fatalf("Failed to load rules: %v", err)
+	}
+
+	playlist := &m3u.Playlist{
+		Tracks: []m3u.Track{
+			{
+				Length:          -1,
+				Name:            "|UK| Channel 1",
+				TVGLogo:         makeURL(t, "http://127.0.0.1/logos/live_stream_1.png"),
+				ExtraAttributes: map[string]string{"tvg_id": "channel-1"},
+			},
+			{
+				Length:     -1,
+				Name:       "Channel 2",
+				GroupTitle: makePointer("Adult"),
+			},
+			{
+				Length: -1,
+				Name:   "Channel 3",
+			},
+		},
+	}
+
+	if err := ruleSet.Apply(playlist); err != nil {
+		t.Fatalf("Failed to apply rules: %v", err)
+	}
+
+	expectedPlaylist := &m3u.Playlist{
+		Tracks: []m3u.Track{
+			{
+				Length:          -1,
+				Name:            "Channel 1",
+				TVGID:           makePointer("channel-1"),
+				TVGLogo:         makeURL(t, "http://127.0.0.1/logos/live_stream_1.png"),
+				ExtraAttributes: map[string]string{"tvg-country": "UK"},
+			},
+			{
+				Length:          -1,
+				Name:            "Channel 3",
+				TVGLogo:         makeURL(t, "http://127.0.0.1/logos/default.png"),
+				ExtraDirectives: []string{"#EXTVLCOPT:http-user-agent=VLC"},
+			},
+		},
+	}
+
+	if diff := cmp.Diff(playlist, expectedPlaylist); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestRuleSetApplyError(t *testing.T) {
+	t.Parallel()
+
+	rules := `[
+	{
+		"match": [{"field": "name", "pattern": "^Channel 1$"}],
+		"actions": [{"type": "drop"}]
+	},
+	{
+		"actions": [
+			{"type": "delete", "field": "tvg-country"},
+			{"type": "set", "field": "length", "value": "${length}"}
+		]
+	}
+]`
+
+	ruleSet, err := m3u.LoadRules(strings.NewReader(rules))
+	if err != nil {
+		t.Fatalf("Failed to load rules: %v", err)
+	}
+
+	playlist := &m3u.Playlist{
+		Tracks: []m3u.Track{
+			{Length: -1, Name: "Channel 1"},
+			{Length: -1, Name: "Channel 2", ExtraAttributes: map[string]string{"tvg-country": "UK"}},
+		},
+	}
+
+	expectedPlaylist := &m3u.Playlist{
+		Tracks: []m3u.Track{
+			{Length: -1, Name: "Channel 1"},
+			{Length: -1, Name: "Channel 2", ExtraAttributes: map[string]string{"tvg-country": "UK"}},
+		},
+	}
+
+	if err := ruleSet.Apply(playlist); err == nil || !strings.Contains(err.Error(), "track 2: rule 2: invalid length") {
+		t.Fatalf("Expected an invalid length error, got: %v", err)
+	}
+
+	if diff := cmp.Diff(playlist, expectedPlaylist); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestLoadRulesFileYAML(t *testing.T) {
+	t.Parallel()
+
+	rules := `- name: strip country prefix
+  match:
+    - field: name
+      pattern: '^\|[A-Z]{2}\|\s*(.*)$'
+  actions:
+    - type: set
+      field: name
+      value: $1
+- match:
+    - field: group-title
+      pattern: (?i)adult
+  actions:
+    - type: drop
+`
+
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	if err := os.WriteFile(path, []byte(rules), 0o644); err != nil {
+		t.Fatalf("Failed to write rules: %v", err)
+	}
+
+	ruleSet, err := m3u.LoadRulesFile(path)
+	if err != nil {
+		t.Fatalf("Failed to load rules: %v", err)
+	}
+
+	playlist := &m3u.Playlist{
+		Tracks: []m3u.Track{
+			{Length: -1, Name: "|UK| Channel 1"},
+			{Length: -1, Name: "Channel 2", GroupTitle: makePointer("Adult")},
+		},
+	}
+
+	if err := ruleSet.Apply(playlist); err != nil {
+		t.Fatalf("Failed to apply rules: %v", err)
+	}
+
+	expectedPlaylist := &m3u.Playlist{
+		Tracks: []m3u.Track{
+			{Length: -1, Name: "Channel 1"},
+		},
+	}
+
+	if diff := cmp.Diff(playlist, expectedPlaylist); diff != "" {
+		t.Error(diff)
+	}
+
+	if _, err := m3u.LoadRulesYAML(strings.NewReader("- actions: [{type: drop}]\n  when: []\n")); err == nil || !strings.Contains(err.Error(), "field when not found") {
+		t.Errorf("Expected an unknown field error, got: %v", err)
+	}
+}
+
+func TestLoadRulesInvalid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		input         string
+		expectedError string
+	}{
+		{
+			name:          "invalid pattern",
+			input:         `[{"match": [{"field": "name", "pattern": "("}], "actions": [{"type": "drop"}]}]`,
+			expectedError: "rule 1: invalid pattern",
+		},
+		{
+			name:          "unknown action",
+			input:         `[{"name": "bad", "actions": [{"type": "explode"}]}]`,
+			expectedError: `rule 1 (bad): unknown action type "explode"`,
+		},
+		{
+			name:          "invalid directive",
+			input:         `[{"actions": [{"type": "add-directive", "value": "EXTVLCOPT"}]}]`,
+			expectedError: "must start with `#`",
+		},
+		{
+			name:          "unknown field",
+			input:         `[{"actions": [{"type": "drop"}], "when": []}]`,
+			expectedError: "unknown field",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := m3u.LoadRules(strings.NewReader(test.input))
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+			if !strings.Contains(err.Error(), test.expectedError) {
+				t.Fatalf("Expected error message to contain %s, got: %v", test.expectedError, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,427 @@
+package xtream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/sherif-fanous/m3u"
+)
+
+// ErrAuthentication is returned when the panel rejects the credentials.
+var ErrAuthentication = errors.New("xtream: authentication failed")
+
+// Client calls the player_api.php endpoints of an Xtream Codes panel.
+type Client struct {
+	// BaseURL is the panel address, for example http://host:8080.
+	BaseURL *url.URL
+	// Username and Password are the account credentials.
+	Username string
+	Password string
+	// HTTPClient is used to make requests. If nil, http.DefaultClient is
+	// used.
+	HTTPClient *http.Client
+}
+
+// NewClient returns a client for the panel at baseURL.
+func NewClient(baseURL, username, password string) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("xtream: invalid base URL: %w", err)
+	}
+
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("xtream: base URL %q must be absolute", baseURL)
+	}
+
+	return &Client{BaseURL: u, Username: username, Password: password}, nil
+}
+
+// Authenticate returns the account information of the user, or
+// ErrAuthentication if the panel rejects the credentials.
+func (c *Client) Authenticate(ctx context.Context) (*Account, error) {
+	var account Account
+	if err := c.get(ctx, "", nil, &account); err != nil {
+		return nil, err
+	}
+
+	if account.UserInfo.Auth.Int() != 1 {
+		return nil, ErrAuthentication
+	}
+
+	return &account, nil
+}
+
+// LiveCategories returns the live TV categories.
+func (c *Client) LiveCategories(ctx context.Context) ([]Category, error) {
+	var categories []Category
+	err := c.get(ctx, "get_live_categories", nil, &categories)
+
+	return categories, err
+}
+
+// LiveStreams returns the live TV channels of the category with the given ID,
+// or of every category if categoryID is empty.
+func (c *Client) LiveStreams(ctx context.Context, categoryID string) ([]LiveStream, error) {
+	var streams []LiveStream
+	err := c.get(ctx, "get_live_streams", categoryParams(categoryID), &streams)
+
+	return streams, err
+}
+
+// VODCategories returns the movie categories.
+func (c *Client) VODCategories(ctx context.Context) ([]Category, error) {
+	var categories []Category
+	err := c.get(ctx, "get_vod_categories", nil, &categories)
+
+	return categories, err
+}
+
+// VODStreams returns the movies of the category with the given ID, or of
+// every category if categoryID is empty.
+func (c *Client) VODStreams(ctx context.Context, categoryID string) ([]VODStream, error) {
+	var streams []VODStream
+	err := c.get(ctx, "get_vod_streams", categoryParams(categoryID), &streams)
+
+	return streams, err
+}
+
+// SeriesCategories returns the series categories.
+func (c *Client) SeriesCategories(ctx context.Context) ([]Category, error) {
+	var categories []Category
+	err := c.get(ctx, "get_series_categories", nil, &categories)
+
+	return categories, err
+}
+
+// Series returns the series of the category with the given ID, or of every
+// category if categoryID is empty.
+func (c *Client) Series(ctx context.Context, categoryID string) ([]SeriesStream, error) {
+	var series []SeriesStream
+	err := c.get(ctx, "get_series", categoryParams(categoryID), &series)
+
+	return series, err
+}
+
+// SeriesInfo returns the episodes of the series with the given ID.
+func (c *Client) SeriesInfo(ctx context.Context, seriesID string) (*SeriesInfo, error) {
+	var info SeriesInfo
+	if err := c.get(ctx, "get_series_info", url.Values{"series_id": {seriesID}}, &info); err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// StreamURL returns the address of the stream with the given kind and ID.
+// The extension is the container format, such as "ts" or "mp4".
+func (c *Client) StreamURL(kind StreamKind, streamID, extension string) *url.URL {
+	stream := &StreamURL{
+		Base:        c.BaseURL,
+		Credentials: Credentials{Username: c.Username, Password: c.Password},
+		Kind:        kind,
+		StreamID:    streamID,
+		Extension:   extension,
+	}
+
+	return stream.URL()
+}
+
+// PlaylistOptions configures Client.Playlist.
+type PlaylistOptions struct {
+	// Kinds lists the kinds of streams to include. If empty, only live
+	// streams are included.
+	Kinds []StreamKind
+	// Output is the container format of live streams. If empty, OutputTS is
+	// used.
+	Output Output
+}
+
+// Playlist builds a playlist from the catalogue of the panel. Category names
+// become group titles, and channel numbers are stored in the tvg-chno
+// attribute. Including series fetches the episodes of every series, one
+// request per series.
+func (c *Client) Playlist(ctx context.Context, opts PlaylistOptions) (*m3u.Playlist, error) {
+	kinds := opts.Kinds
+	if len(kinds) == 0 {
+		kinds = []StreamKind{Live}
+	}
+
+	output := opts.Output
+	if output == "" {
+		output = OutputTS
+	}
+
+	playlist := &m3u.Playlist{TVGURL: c.xmltvURL()}
+
+	if slices.Contains(kinds, Live) {
+		if err := c.appendLive(ctx, playlist, output); err != nil {
+			return nil, err
+		}
+	}
+
+	if slices.Contains(kinds, Movie) {
+		if err := c.appendVOD(ctx, playlist); err != nil {
+			return nil, err
+		}
+	}
+
+	if slices.Contains(kinds, Series) {
+		if err := c.appendSeries(ctx, playlist); err != nil {
+			return nil, err
+		}
+	}
+
+	return playlist, nil
+}
+
+func (c *Client) appendLive(ctx context.Context, playlist *m3u.Playlist, output Output) error {
+	categories, err := c.LiveCategories(ctx)
+	if err != nil {
+		return err
+	}
+
+	streams, err := c.LiveStreams(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	names := categoryNames(categories)
+
+	for _, stream := range streams {
+		track := m3u.Track{
+			Length:     -1,
+			Name:       stream.Name,
+			TVGName:    &stream.Name,
+			TVGLogo:    parseOptionalURL(stream.StreamIcon),
+			GroupTitle: names[stream.CategoryID],
+			URL:        c.StreamURL(Live, string(stream.StreamID), string(output)),
+		}
+
+		if stream.EPGChannelID != "" {
+			track.TVGID = &stream.EPGChannelID
+		}
+
+		if stream.Num != "" {
+			track.ExtraAttributes = map[string]string{"tvg-chno": string(stream.Num)}
+		}
+
+		playlist.Tracks = append(playlist.Tracks, track)
+	}
+
+	return nil
+}
+
+func (c *Client) appendVOD(ctx context.Context, playlist *m3u.Playlist) error {
+	categories, err := c.VODCategories(ctx)
+	if err != nil {
+		return err
+	}
+
+	streams, err := c.VODStreams(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	names := categoryNames(categories)
+
+	for _, stream := range streams {
+		playlist.Tracks = append(playlist.Tracks, m3u.Track{
+			Length:     -1,
+			Name:       stream.Name,
+			TVGName:    &stream.Name,
+			TVGLogo:    parseOptionalURL(stream.StreamIcon),
+			GroupTitle: names[stream.CategoryID],
+			URL:        c.StreamURL(Movie, string(stream.StreamID), extensionOr(stream.ContainerExtension, "mp4")),
+		})
+	}
+
+	return nil
+}
+
+func (c *Client) appendSeries(ctx context.Context, playlist *m3u.Playlist) error {
+	categories, err := c.SeriesCategories(ctx)
+	if err != nil {
+		return err
+	}
+
+	series, err := c.Series(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	names := categoryNames(categories)
+
+	for _, s := range series {
+		info, err := c.SeriesInfo(ctx, string(s.SeriesID))
+		if err != nil {
+			return err
+		}
+
+		seasons := make([]string, 0, len(info.Episodes))
+		for season := range info.Episodes {
+			seasons = append(seasons, season)
+		}
+
+		// Seasons are keyed by number, so sort them numerically
+		slices.SortFunc(seasons, func(a, b string) int {
+			return FlexString(a).Int() - FlexString(b).Int()
+		})
+
+		for _, season := range seasons {
+			for _, episode := range info.Episodes[season] {
+				length := float64(-1)
+				if secs := episode.Info.DurationSecs.Int(); secs > 0 {
+					length = float64(secs)
+				}
+
+				name := episode.Title
+				if name == "" {
+					name = fmt.Sprintf("%s S%02dE%02d", s.Name, FlexString(season).Int(), episode.EpisodeNum.Int())
+				}
+
+				playlist.Tracks = append(playlist.Tracks, m3u.Track{
+					Length:     length,
+					Name:       name,
+					TVGName:    &name,
+					TVGLogo:    parseOptionalURL(s.Cover),
+					GroupTitle: names[s.CategoryID],
+					URL:        c.StreamURL(Series, string(episode.ID), extensionOr(episode.ContainerExtension, "mp4")),
+				})
+			}
+		}
+	}
+
+	return nil
+}
+
+// get calls the player_api.php endpoint with the given action and decodes the
+// JSON response into v.
+func (c *Client) get(ctx context.Context, action string, params url.Values, v any) error {
+	query := url.Values{
+		"username": {c.Username},
+		"password": {c.Password},
+	}
+
+	if action != "" {
+		query.Set("action", action)
+	}
+
+	for key, values := range params {
+		query[key] = values
+	}
+
+	u := joinPath(c.BaseURL, "player_api.php")
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("xtream: %w", err)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		// The URL of the error carries the credentials in its query
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = &url.Error{Op: urlErr.Op, URL: redactURL(u), Err: urlErr.Err}
+		}
+
+		return fmt.Errorf("xtream: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return ErrAuthentication
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("xtream: %s: unexpected status %s", describeAction(action), resp.Status)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("xtream: %s: invalid response: %w", describeAction(action), err)
+	}
+
+	return nil
+}
+
+// xmltvURL returns the address of the panel's EPG.
+func (c *Client) xmltvURL() *url.URL {
+	u := joinPath(c.BaseURL, "xmltv.php")
+	u.RawQuery = url.Values{
+		"username": {c.Username},
+		"password": {c.Password},
+	}.Encode()
+
+	return u
+}
+
+// joinPath returns a copy of base with elem joined to its path, which is made
+// absolute.
+func joinPath(base *url.URL, elem ...string) *url.URL {
+	u := base.JoinPath(elem...)
+	if !strings.HasPrefix(u.Path, "/") {
+		u.Path = "/" + u.Path
+	}
+
+	return u
+}
+
+func describeAction(action string) string {
+	if action == "" {
+		return "authenticate"
+	}
+
+	return action
+}
+
+func categoryParams(categoryID string) url.Values {
+	if categoryID == "" {
+		return nil
+	}
+
+	return url.Values{"category_id": {categoryID}}
+}
+
+// categoryNames maps category IDs to their names.
+func categoryNames(categories []Category) map[FlexString]*string {
+	names := make(map[FlexString]*string, len(categories))
+	for _, category := range categories {
+		name := strings.TrimSpace(category.CategoryName)
+		names[category.CategoryID] = &name
+	}
+
+	return names
+}
+
+// parseOptionalURL parses s, returning nil if it is empty or invalid.
+func parseOptionalURL(s string) *url.URL {
+	if s == "" {
+		return nil
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil
+	}
+
+	return u
+}
+
+func extensionOr(extension, fallback string) string {
+	if extension == "" {
+		return fallback
+	}
+
+	return extension
+}
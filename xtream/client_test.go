@@ -0,0 +1,200 @@
+package xtream_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sherif-fanous/m3u"
+	"github.com/sherif-fanous/m3u/xtream"
+)
+
+func makePointer[T any](t T) *T {
+	return &t
+}
+
+func makeURL(t *testing.T, s string) *url.URL {
+	t.Helper()
+
+	u, err := url.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return u
+}
+
+// newPanel returns a stand-in for the player_api.php endpoint of an Xtream
+// Codes panel with a single user.
+func newPanel(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	responses := map[string]string{
+		"": `{"user_info": {"username": "user", "password": "pass", "auth": 1, "status": "Active"},
+			"server_info": {"url": "127.0.0.1", "port": "80"}}`,
+		"get_live_categories": `[{"category_id": "1", "category_name": "News", "parent_id": 0}]`,
+		"get_live_streams": `[
+			{"num": 1, "name": "Channel 1", "stream_type": "live", "stream_id": 101,
+				"stream_icon": "http://127.0.0.1/logos/1.png", "epg_channel_id": "channel-1", "category_id": "1"},
+			{"num": "2", "name": "Channel 2", "stream_type": "live", "stream_id": "102",
+				"stream_icon": "", "epg_channel_id": null, "category_id": "9"}
+		]`,
+		"get_vod_categories": `[{"category_id": 5, "category_name": "Movies"}]`,
+		"get_vod_streams": `[
+			{"num": 1, "name": "Movie 1", "stream_type": "movie", "stream_id": 201,
+				"stream_icon": "http://127.0.0.1/covers/201.jpg", "category_id": "5", "container_extension": "mkv"}
+		]`,
+		"get_series_categories": `[{"category_id": "7", "category_name": "Shows"}]`,
+		"get_series":            `[{"num": 1, "name": "Show", "series_id": 301, "cover": "", "category_id": "7"}]`,
+		"get_series_info": `{"episodes": {
+			"2": [{"id": "402", "episode_num": 1, "title": "", "container_extension": "mp4", "info": []}],
+			"1": [{"id": "401", "episode_num": 1, "title": "Pilot", "container_extension": "mp4",
+				"info": {"duration_secs": 1800}}]
+		}}`,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		if r.URL.Path != "/player_api.php" || query.Get("username") != "user" || query.Get("password") != "pass" {
+			w.Write([]byte(`{"user_info": {"auth": 0}}`))
+			return
+		}
+
+		response, ok := responses[query.Get("action")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestClientAuthenticate(t *testing.T) {
+	t.Parallel()
+
+	server := newPanel(t)
+
+	client, err := xtream.NewClient(server.URL, "user", "pass")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	account, err := client.Authenticate(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to authenticate: %v", err)
+	}
+
+	if account.UserInfo.Status != "Active" {
+		t.Errorf("Expected status Active, got %q", account.UserInfo.Status)
+	}
+
+	client.Password = "wrong"
+
+	if _, err := client.Authenticate(context.Background()); !errors.Is(err, xtream.ErrAuthentication) {
+		t.Errorf("Expected ErrAuthentication, got: %v", err)
+	}
+}
+
+func TestClientRedactsNetworkErrors(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	client, err := xtream.NewClient(server.URL, "user", "secret")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	_, err = client.Authenticate(context.Background())
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		t.Fatalf("Expected a *url.Error, got: %v", err)
+	}
+
+	if strings.Contains(err.Error(), "secret") {
+		t.Errorf("Expected the password to be redacted, got: %v", err)
+	}
+}
+
+func TestClientPlaylist(t *testing.T) {
+	t.Parallel()
+
+	server := newPanel(t)
+
+	client, err := xtream.NewClient(server.URL, "user", "pass")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	playlist, err := client.Playlist(context.Background(), xtream.PlaylistOptions{
+		Kinds:  []xtream.StreamKind{xtream.Live, xtream.Movie, xtream.Series},
+		Output: xtream.OutputHLS,
+	})
+	if err != nil {
+		t.Fatalf("Failed to build playlist: %v", err)
+	}
+
+	expectedPlaylist := &m3u.Playlist{
+		TVGURL: makeURL(t, server.URL+"/xmltv.php?password=pass&username=user"),
+		Tracks: []m3u.Track{
+			{
+				Length:          -1,
+				Name:            "Channel 1",
+				TVGID:           makePointer("channel-1"),
+				TVGName:         makePointer("Channel 1"),
+				TVGLogo:         makeURL(t, "http://127.0.0.1/logos/1.png"),
+				GroupTitle:      makePointer("News"),
+				URL:             makeURL(t, server.URL+"/live/user/pass/101.m3u8"),
+				ExtraAttributes: map[string]string{"tvg-chno": "1"},
+			},
+			{
+				Length:          -1,
+				Name:            "Channel 2",
+				TVGName:         makePointer("Channel 2"),
+				URL:             makeURL(t, server.URL+"/live/user/pass/102.m3u8"),
+				ExtraAttributes: map[string]string{"tvg-chno": "2"},
+			},
+			{
+				Length:     -1,
+				Name:       "Movie 1",
+				TVGName:    makePointer("Movie 1"),
+				TVGLogo:    makeURL(t, "http://127.0.0.1/covers/201.jpg"),
+				GroupTitle: makePointer("Movies"),
+				URL:        makeURL(t, server.URL+"/movie/user/pass/201.mkv"),
+			},
+			{
+				Length:     1800,
+				Name:       "Pilot",
+				TVGName:    makePointer("Pilot"),
+				GroupTitle: makePointer("Shows"),
+				URL:        makeURL(t, server.URL+"/series/user/pass/401.mp4"),
+			},
+			{
+				Length:     -1,
+				Name:       "Show S02E01",
+				TVGName:    makePointer("Show S02E01"),
+				GroupTitle: makePointer("Shows"),
+				URL:        makeURL(t, server.URL+"/series/user/pass/402.mp4"),
+			},
+		},
+	}
+
+	if diff := cmp.Diff(playlist, expectedPlaylist); diff != "" {
+		t.Error(diff)
+	}
+}
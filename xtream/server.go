@@ -0,0 +1,469 @@
+package xtream
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sherif-fanous/m3u"
+)
+
+// User is an account accepted by a Server.
+type User struct {
+	Username string
+	Password string
+	// MaxConnections is reported to players. It is not enforced.
+	MaxConnections int
+	// ExpiresAt is when the account expires. The zero value never expires.
+	ExpiresAt time.Time
+}
+
+// Server exposes a playlist through the player_api.php, get.php and
+// xmltv.php endpoints of an Xtream Codes panel, so that players that only
+// accept Xtream logins can consume it.
+//
+// Group titles become categories, and streams are served as redirects to the
+// track URLs. Tracks are served as movies if their URL is an Xtream movie or
+// series URL, or if they have a positive length, and as live channels
+// otherwise. The xmltv.php endpoint lists the live channels that have a TVG
+// ID, without programmes; the guide of the playlist is not exposed, as its URL
+// may carry upstream credentials.
+type Server struct {
+	playlist *m3u.Playlist
+	users    map[string]User
+	streams  []serverStream
+	// categories holds the categories of each stream kind, in order of first
+	// appearance.
+	categories map[StreamKind][]serverCategory
+}
+
+type serverStream struct {
+	id         int
+	kind       StreamKind
+	track      *m3u.Track
+	categoryID string
+	extension  string
+}
+
+type serverCategory struct {
+	id   string
+	name string
+}
+
+// NewServer returns a server for playlist and users. The playlist must not be
+// modified while the server is in use.
+func NewServer(playlist *m3u.Playlist, users ...User) *Server {
+	s := &Server{
+		playlist:   playlist,
+		users:      make(map[string]User, len(users)),
+		categories: make(map[StreamKind][]serverCategory),
+	}
+
+	for _, user := range users {
+		s.users[user.Username] = user
+	}
+
+	categoryIDs := make(map[string]string)
+
+	for i := range playlist.Tracks {
+		track := &playlist.Tracks[i]
+		kind := classifyTrack(track)
+
+		stream := serverStream{id: i + 1, kind: kind, track: track, extension: "ts"}
+		if kind == Movie {
+			stream.extension = trackExtension(track, "mp4")
+		}
+
+		if track.GroupTitle != nil && *track.GroupTitle != "" {
+			key := string(kind) + "\x00" + *track.GroupTitle
+
+			id, ok := categoryIDs[key]
+			if !ok {
+				id = strconv.Itoa(len(categoryIDs) + 1)
+				categoryIDs[key] = id
+				s.categories[kind] = append(s.categories[kind], serverCategory{id: id, name: *track.GroupTitle})
+			}
+
+			stream.categoryID = id
+		}
+
+		s.streams = append(s.streams, stream)
+	}
+
+	return s
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/player_api.php":
+		s.servePlayerAPI(w, r)
+	case "/get.php":
+		s.servePlaylist(w, r)
+	case "/xmltv.php":
+		s.serveXMLTV(w, r)
+	default:
+		s.serveStream(w, r)
+	}
+}
+
+func (s *Server) servePlayerAPI(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	user, ok := s.authenticate(query.Get("username"), query.Get("password"))
+	if !ok {
+		writeJSON(w, map[string]any{"user_info": map[string]any{"auth": 0}})
+		return
+	}
+
+	categoryID := query.Get("category_id")
+
+	switch action := query.Get("action"); action {
+	case "":
+		writeJSON(w, s.accountResponse(r, user))
+	case "get_live_categories":
+		writeJSON(w, s.categoryResponse(Live))
+	case "get_vod_categories":
+		writeJSON(w, s.categoryResponse(Movie))
+	case "get_series_categories":
+		writeJSON(w, s.categoryResponse(Series))
+	case "get_live_streams":
+		writeJSON(w, s.liveStreamResponse(categoryID))
+	case "get_vod_streams":
+		writeJSON(w, s.vodStreamResponse(categoryID))
+	case "get_series":
+		writeJSON(w, []any{})
+	case "get_short_epg", "get_simple_data_table":
+		writeJSON(w, map[string]any{"epg_listings": []any{}})
+	default:
+		http.Error(w, fmt.Sprintf("unsupported action %q", action), http.StatusBadRequest)
+	}
+}
+
+func (s *Server) servePlaylist(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	user, ok := s.authenticate(query.Get("username"), query.Get("password"))
+	if !ok {
+		http.Error(w, "authentication failed", http.StatusUnauthorized)
+		return
+	}
+
+	playlistType := m3u.M3UPlus
+	if query.Get("type") == "m3u" {
+		playlistType = m3u.M3U
+	}
+
+	output := Output(query.Get("output"))
+	if output == "hls" {
+		output = OutputHLS
+	}
+
+	if output != OutputHLS {
+		output = OutputTS
+	}
+
+	base := requestBaseURL(r)
+	playlist := &m3u.Playlist{
+		TVGURL:          credentialURL(base, "xmltv.php", user),
+		ExtraAttributes: s.playlist.ExtraAttributes,
+	}
+
+	for _, stream := range s.streams {
+		track := *stream.track
+		// The alternates are upstream URLs, which may carry credentials
+		track.AlternateURLs = nil
+
+		extension := stream.extension
+		if stream.kind == Live {
+			extension = string(output)
+		}
+
+		track.URL = base.JoinPath(string(stream.kind), user.Username, user.Password, strconv.Itoa(stream.id)+"."+extension)
+		playlist.Tracks = append(playlist.Tracks, track)
+	}
+
+	w.Header().Set("Content-Type", "audio/x-mpegurl")
+	m3u.NewEncoder(w).Encode(playlist, playlistType)
+}
+
+// xmltv is a minimal XMLTV document listing channels without programmes.
+type xmltv struct {
+	XMLName  xml.Name       `xml:"tv"`
+	Channels []xmltvChannel `xml:"channel"`
+}
+
+type xmltvChannel struct {
+	ID          string     `xml:"id,attr"`
+	DisplayName string     `xml:"display-name"`
+	Icon        *xmltvIcon `xml:"icon,omitempty"`
+}
+
+type xmltvIcon struct {
+	Src string `xml:"src,attr"`
+}
+
+func (s *Server) serveXMLTV(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	if _, ok := s.authenticate(query.Get("username"), query.Get("password")); !ok {
+		http.Error(w, "authentication failed", http.StatusUnauthorized)
+		return
+	}
+
+	doc := xmltv{}
+
+	for _, stream := range s.streams {
+		if stream.kind != Live || stream.track.TVGID == nil {
+			continue
+		}
+
+		channel := xmltvChannel{ID: *stream.track.TVGID, DisplayName: stream.track.Name}
+		if stream.track.TVGLogo != nil {
+			channel.Icon = &xmltvIcon{Src: stream.track.TVGLogo.String()}
+		}
+
+		doc.Channels = append(doc.Channels, channel)
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write([]byte(xml.Header))
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	encoder.Encode(doc)
+}
+
+// serveStream redirects /live/user/pass/id.ext, /movie/user/pass/id.ext and
+// /user/pass/id requests to the URL of the stream.
+func (s *Server) serveStream(w http.ResponseWriter, r *http.Request) {
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch {
+	case len(segments) == 4 && (segments[0] == string(Live) || segments[0] == string(Movie) || segments[0] == string(Series)):
+		segments = segments[1:]
+	case len(segments) != 3:
+		http.NotFound(w, r)
+		return
+	}
+
+	if _, ok := s.authenticate(segments[0], segments[1]); !ok {
+		http.Error(w, "authentication failed", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(strings.TrimSuffix(segments[2], path.Ext(segments[2])))
+	if err != nil || id < 1 || id > len(s.streams) || s.streams[id-1].track.URL == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	http.Redirect(w, r, s.streams[id-1].track.URL.String(), http.StatusFound)
+}
+
+func (s *Server) authenticate(username, password string) (User, bool) {
+	user, ok := s.users[username]
+	if !ok || subtle.ConstantTimeCompare([]byte(user.Password), []byte(password)) != 1 {
+		return User{}, false
+	}
+
+	if !user.ExpiresAt.IsZero() && time.Now().After(user.ExpiresAt) {
+		return User{}, false
+	}
+
+	return user, true
+}
+
+func (s *Server) accountResponse(r *http.Request, user User) any {
+	base := requestBaseURL(r)
+
+	host, port, err := net.SplitHostPort(base.Host)
+	if err != nil {
+		host = base.Host
+		port = "80"
+		if base.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	expDate := ""
+	if !user.ExpiresAt.IsZero() {
+		expDate = strconv.FormatInt(user.ExpiresAt.Unix(), 10)
+	}
+
+	now := time.Now()
+
+	return map[string]any{
+		"user_info": map[string]any{
+			"username":               user.Username,
+			"password":               user.Password,
+			"message":                "",
+			"auth":                   1,
+			"status":                 "Active",
+			"exp_date":               expDate,
+			"is_trial":               "0",
+			"active_cons":            "0",
+			"created_at":             "",
+			"max_connections":        strconv.Itoa(user.MaxConnections),
+			"allowed_output_formats": []string{string(OutputTS), string(OutputHLS)},
+		},
+		"server_info": map[string]any{
+			"url":             host,
+			"port":            port,
+			"https_port":      port,
+			"server_protocol": base.Scheme,
+			"rtmp_port":       "",
+			"timezone":        "UTC",
+			"timestamp_now":   now.Unix(),
+			"time_now":        now.UTC().Format(time.DateTime),
+		},
+	}
+}
+
+func (s *Server) categoryResponse(kind StreamKind) any {
+	categories := []map[string]any{}
+
+	for _, category := range s.categories[kind] {
+		categories = append(categories, map[string]any{
+			"category_id":   category.id,
+			"category_name": category.name,
+			"parent_id":     0,
+		})
+	}
+
+	return categories
+}
+
+func (s *Server) liveStreamResponse(categoryID string) any {
+	streams := []map[string]any{}
+
+	for _, stream := range s.streams {
+		if stream.kind != Live || (categoryID != "" && stream.categoryID != categoryID) {
+			continue
+		}
+
+		streams = append(streams, map[string]any{
+			"num":                 stream.id,
+			"name":                stream.track.Name,
+			"stream_type":         "live",
+			"stream_id":           stream.id,
+			"stream_icon":         urlString(stream.track.TVGLogo),
+			"epg_channel_id":      stringValue(stream.track.TVGID),
+			"added":               "",
+			"category_id":         stream.categoryID,
+			"custom_sid":          "",
+			"tv_archive":          0,
+			"direct_source":       "",
+			"tv_archive_duration": 0,
+		})
+	}
+
+	return streams
+}
+
+func (s *Server) vodStreamResponse(categoryID string) any {
+	streams := []map[string]any{}
+
+	for _, stream := range s.streams {
+		if stream.kind != Movie || (categoryID != "" && stream.categoryID != categoryID) {
+			continue
+		}
+
+		streams = append(streams, map[string]any{
+			"num":                 stream.id,
+			"name":                stream.track.Name,
+			"stream_type":         "movie",
+			"stream_id":           stream.id,
+			"stream_icon":         urlString(stream.track.TVGLogo),
+			"rating":              "",
+			"added":               "",
+			"category_id":         stream.categoryID,
+			"container_extension": stream.extension,
+			"custom_sid":          "",
+			"direct_source":       "",
+		})
+	}
+
+	return streams
+}
+
+// classifyTrack returns the kind of stream a track is served as.
+func classifyTrack(track *m3u.Track) StreamKind {
+	if track.URL != nil {
+		segments := strings.Split(strings.Trim(track.URL.Path, "/"), "/")
+		if len(segments) == 4 && (segments[0] == string(Movie) || segments[0] == string(Series)) {
+			return Movie
+		}
+	}
+
+	if track.Length > 0 {
+		return Movie
+	}
+
+	return Live
+}
+
+// trackExtension returns the file extension of the track URL, or fallback if
+// it has none.
+func trackExtension(track *m3u.Track, fallback string) string {
+	if track.URL == nil {
+		return fallback
+	}
+
+	if ext := strings.TrimPrefix(path.Ext(track.URL.Path), "."); ext != "" {
+		return ext
+	}
+
+	return fallback
+}
+
+// requestBaseURL returns the scheme and host the request was sent to.
+func requestBaseURL(r *http.Request) *url.URL {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	return &url.URL{Scheme: scheme, Host: r.Host, Path: "/"}
+}
+
+// credentialURL returns the address of endpoint with the user's credentials
+// in the query string.
+func credentialURL(base *url.URL, endpoint string, user User) *url.URL {
+	u := base.JoinPath(endpoint)
+	u.RawQuery = url.Values{
+		"username": {user.Username},
+		"password": {user.Password},
+	}.Encode()
+
+	return u
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func urlString(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+
+	return u.String()
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
@@ -0,0 +1,218 @@
+package xtream_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sherif-fanous/m3u"
+	"github.com/sherif-fanous/m3u/xtream"
+)
+
+func newServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	playlist := &m3u.Playlist{
+		TVGURL: makeURL(t, "http://upstream.example.com/xmltv.php?username=upstream&password=secret"),
+		Tracks: []m3u.Track{
+			{
+				Length:     -1,
+				Name:       "Channel 1",
+				TVGID:      makePointer("channel-1"),
+				TVGLogo:    makeURL(t, "http://127.0.0.1/logos/1.png"),
+				GroupTitle: makePointer("News"),
+				URL:        makeURL(t, "http://127.0.0.1/stream_1"),
+			},
+			{
+				Length:     -1,
+				Name:       "Channel 2",
+				GroupTitle: makePointer("Sports"),
+				URL:        makeURL(t, "http://127.0.0.1/stream_2"),
+				AlternateURLs: []*url.URL{
+					makeURL(t, "http://upstream.example.com/live/realuser/realpass/2.ts"),
+				},
+			},
+			{
+				Length:     5400,
+				Name:       "Movie 1",
+				GroupTitle: makePointer("Movies"),
+				URL:        makeURL(t, "http://127.0.0.1/movies/movie_1.mkv"),
+			},
+		},
+	}
+
+	server := httptest.NewServer(xtream.NewServer(playlist, xtream.User{Username: "user", Password: "pass"}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestServerPlayerAPI(t *testing.T) {
+	t.Parallel()
+
+	server := newServer(t)
+
+	client, err := xtream.NewClient(server.URL, "user", "pass")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	if _, err := client.Authenticate(context.Background()); err != nil {
+		t.Fatalf("Failed to authenticate: %v", err)
+	}
+
+	playlist, err := client.Playlist(context.Background(), xtream.PlaylistOptions{
+		Kinds: []xtream.StreamKind{xtream.Live, xtream.Movie},
+	})
+	if err != nil {
+		t.Fatalf("Failed to build playlist: %v", err)
+	}
+
+	expectedPlaylist := &m3u.Playlist{
+		TVGURL: makeURL(t, server.URL+"/xmltv.php?password=pass&username=user"),
+		Tracks: []m3u.Track{
+			{
+				Length:          -1,
+				Name:            "Channel 1",
+				TVGID:           makePointer("channel-1"),
+				TVGName:         makePointer("Channel 1"),
+				TVGLogo:         makeURL(t, "http://127.0.0.1/logos/1.png"),
+				GroupTitle:      makePointer("News"),
+				URL:             makeURL(t, server.URL+"/live/user/pass/1.ts"),
+				ExtraAttributes: map[string]string{"tvg-chno": "1"},
+			},
+			{
+				Length:          -1,
+				Name:            "Channel 2",
+				TVGName:         makePointer("Channel 2"),
+				GroupTitle:      makePointer("Sports"),
+				URL:             makeURL(t, server.URL+"/live/user/pass/2.ts"),
+				ExtraAttributes: map[string]string{"tvg-chno": "2"},
+			},
+			{
+				Length:     -1,
+				Name:       "Movie 1",
+				TVGName:    makePointer("Movie 1"),
+				GroupTitle: makePointer("Movies"),
+				URL:        makeURL(t, server.URL+"/movie/user/pass/3.mkv"),
+			},
+		},
+	}
+
+	if diff := cmp.Diff(playlist, expectedPlaylist); diff != "" {
+		t.Error(diff)
+	}
+
+	client.Password = "wrong"
+
+	if _, err := client.Authenticate(context.Background()); err == nil {
+		t.Error("Expected authentication to fail")
+	}
+}
+
+func TestServerGetPlaylistAndStreams(t *testing.T) {
+	t.Parallel()
+
+	server := newServer(t)
+
+	resp, err := http.Get(server.URL + "/get.php?username=user&password=pass&type=m3u_plus&output=hls")
+	if err != nil {
+		t.Fatalf("Failed to get playlist: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read playlist: %v", err)
+	}
+
+	if strings.Contains(string(data), "realpass") {
+		t.Errorf("Expected the upstream alternate URLs not to be served, got:\n%s", data)
+	}
+
+	playlist, err := m3u.Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal playlist: %v", err)
+	}
+
+	if len(playlist.Tracks) != 3 {
+		t.Errorf("Expected 3 tracks, got %d", len(playlist.Tracks))
+	}
+
+	if got, expected := playlist.Tracks[0].URL.String(), server.URL+"/live/user/pass/1.m3u8"; got != expected {
+		t.Errorf("Expected first track URL %s, got %s", expected, got)
+	}
+
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	tests := []struct {
+		path             string
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{"/live/user/pass/1.ts", http.StatusFound, "http://127.0.0.1/stream_1"},
+		{"/user/pass/2", http.StatusFound, "http://127.0.0.1/stream_2"},
+		{"/movie/user/pass/3.mkv", http.StatusFound, "http://127.0.0.1/movies/movie_1.mkv"},
+		{"/live/user/pass/4.ts", http.StatusNotFound, ""},
+		{"/live/user/wrong/1.ts", http.StatusUnauthorized, ""},
+		{"/get.php?username=user&password=wrong", http.StatusUnauthorized, ""},
+	}
+
+	for _, test := range tests {
+		resp, err := client.Get(server.URL + test.path)
+		if err != nil {
+			t.Fatalf("Failed to get %s: %v", test.path, err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != test.expectedStatus {
+			t.Errorf("%s: expected status %d, got %d", test.path, test.expectedStatus, resp.StatusCode)
+		}
+
+		if location := resp.Header.Get("Location"); location != test.expectedLocation {
+			t.Errorf("%s: expected location %q, got %q", test.path, test.expectedLocation, location)
+		}
+	}
+}
+
+func TestServerXMLTV(t *testing.T) {
+	t.Parallel()
+
+	server := newServer(t)
+
+	resp, err := http.Get(server.URL + "/xmltv.php?username=user&password=pass")
+	if err != nil {
+		t.Fatalf("Failed to get XMLTV: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read XMLTV: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	if strings.Contains(string(data), "secret") {
+		t.Errorf("Expected the upstream credentials not to be served, got:\n%s", data)
+	}
+
+	expected := `<channel id="channel-1">
+    <display-name>Channel 1</display-name>
+    <icon src="http://127.0.0.1/logos/1.png"></icon>
+  </channel>`
+	if !strings.Contains(string(data), expected) {
+		t.Errorf("Expected XMLTV to contain:\n%s\nGot:\n%s", expected, data)
+	}
+}
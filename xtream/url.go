@@ -0,0 +1,298 @@
+package xtream
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/sherif-fanous/m3u"
+)
+
+// ErrNotXtreamURL is returned when a URL does not have the shape of an Xtream
+// Codes playlist or stream URL.
+var ErrNotXtreamURL = errors.New("xtream: not an Xtream Codes URL")
+
+// Credentials are the username and password of an Xtream Codes account.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// PlaylistURL is the address of a get.php playlist, such as
+// http://host/get.php?username=u&password=p&type=m3u_plus&output=ts.
+type PlaylistURL struct {
+	// Base is the panel address the get.php endpoint is relative to.
+	Base *url.URL
+	Credentials
+	// Type is the playlist type, usually "m3u" or "m3u_plus".
+	Type string
+	// Output is the container format of live streams.
+	Output Output
+	// Query holds the query parameters not covered by the other fields.
+	Query url.Values
+}
+
+// ParsePlaylistURL parses a get.php playlist address.
+func ParsePlaylistURL(rawURL string) (*PlaylistURL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("xtream: %w", err)
+	}
+
+	dir, file := path.Split(u.Path)
+	if file != "get.php" {
+		return nil, fmt.Errorf("%w: %q is not a get.php URL", ErrNotXtreamURL, redactURL(u))
+	}
+
+	query := u.Query()
+	if !query.Has("username") || !query.Has("password") {
+		return nil, fmt.Errorf("%w: %q has no credentials", ErrNotXtreamURL, redactURL(u))
+	}
+
+	p := &PlaylistURL{
+		Base: &url.URL{Scheme: u.Scheme, Host: u.Host, Path: dir},
+		Credentials: Credentials{
+			Username: query.Get("username"),
+			Password: query.Get("password"),
+		},
+		Type:   query.Get("type"),
+		Output: Output(query.Get("output")),
+	}
+
+	for _, key := range []string{"username", "password", "type", "output"} {
+		query.Del(key)
+	}
+
+	if len(query) > 0 {
+		p.Query = query
+	}
+
+	return p, nil
+}
+
+// URL returns the address of the playlist.
+func (p *PlaylistURL) URL() *url.URL {
+	u := joinPath(p.Base, "get.php")
+
+	query := url.Values{}
+	for key, values := range p.Query {
+		query[key] = values
+	}
+
+	query.Set("username", p.Username)
+	query.Set("password", p.Password)
+
+	if p.Type != "" {
+		query.Set("type", p.Type)
+	}
+
+	if p.Output != "" {
+		query.Set("output", string(p.Output))
+	}
+
+	u.RawQuery = query.Encode()
+
+	return u
+}
+
+// String returns the address of the playlist.
+func (p *PlaylistURL) String() string {
+	return p.URL().String()
+}
+
+// StreamURL is the address of a single stream, either in the
+// /kind/username/password/id.ext form, such as /live/u/p/123.ts, or in the
+// short /username/password/id form used for live streams.
+type StreamURL struct {
+	// Base is the panel address the stream path is relative to.
+	Base *url.URL
+	Credentials
+	// Kind is the kind of stream, or empty for the short form.
+	Kind StreamKind
+	// StreamID is the numeric identifier of the stream.
+	StreamID string
+	// Extension is the container format without the leading dot, or empty.
+	Extension string
+}
+
+// ParseStreamURL parses the address of a stream. Only http and https URLs are
+// accepted, whose path has a stream kind segment followed by the username,
+// password and numeric file name, or is exactly /username/password/id without
+// an extension, as other paths ending in a number are too common.
+func ParseStreamURL(u *url.URL) (*StreamURL, error) {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("%w: scheme is not http or https", ErrNotXtreamURL)
+	}
+
+	segments := strings.Split(strings.TrimPrefix(u.Path, "/"), "/")
+
+	n := len(segments)
+	if n < 3 || segments[n-3] == "" || segments[n-2] == "" {
+		return nil, fmt.Errorf("%w: path has no credentials", ErrNotXtreamURL)
+	}
+
+	if n > 3 && !isStreamKind(segments[n-4]) || n == 3 && path.Ext(segments[2]) != "" {
+		return nil, fmt.Errorf("%w: path has no stream kind", ErrNotXtreamURL)
+	}
+
+	file := segments[n-1]
+	s := &StreamURL{
+		Credentials: Credentials{Username: segments[n-3], Password: segments[n-2]},
+		StreamID:    strings.TrimSuffix(file, path.Ext(file)),
+		Extension:   strings.TrimPrefix(path.Ext(file), "."),
+	}
+
+	if _, err := strconv.ParseUint(s.StreamID, 10, 64); err != nil {
+		return nil, fmt.Errorf("%w: path has no numeric stream ID", ErrNotXtreamURL)
+	}
+
+	prefix := segments[:n-3]
+	if len(prefix) > 0 {
+		s.Kind = StreamKind(prefix[len(prefix)-1])
+		prefix = prefix[:len(prefix)-1]
+	}
+
+	s.Base = &url.URL{Scheme: u.Scheme, User: u.User, Host: u.Host, Path: "/" + path.Join(prefix...)}
+
+	return s, nil
+}
+
+// URL returns the address of the stream.
+func (s *StreamURL) URL() *url.URL {
+	file := s.StreamID
+	if s.Extension != "" {
+		file += "." + s.Extension
+	}
+
+	elem := append(s.kindSegment(), s.Username, s.Password, file)
+
+	return joinPath(s.Base, elem...)
+}
+
+// String returns the address of the stream.
+func (s *StreamURL) String() string {
+	return s.URL().String()
+}
+
+// SetOutput changes the container format of a live stream. Streams in the
+// short form are converted to the /live/ form. Other kinds of streams are
+// left unchanged.
+func (s *StreamURL) SetOutput(output Output) {
+	if s.Kind != Live && s.Kind != "" {
+		return
+	}
+
+	s.Kind = Live
+	s.Extension = string(output)
+}
+
+func (s *StreamURL) kindSegment() []string {
+	if s.Kind == "" {
+		return nil
+	}
+
+	return []string{string(s.Kind)}
+}
+
+// RewriteStreams calls rewrite for every track of playlist whose URL is an
+// Xtream Codes stream URL, as accepted by ParseStreamURL, and replaces the
+// track URL with the rewritten one. When hosts are given, only the URLs on
+// these hosts, with or without their port, are rewritten. It returns the
+// number of tracks rewritten.
+func RewriteStreams(playlist *m3u.Playlist, rewrite func(*StreamURL), hosts ...string) int {
+	count := 0
+
+	for i := range playlist.Tracks {
+		track := &playlist.Tracks[i]
+		if track.URL == nil || !onHosts(track.URL, hosts) {
+			continue
+		}
+
+		stream, err := ParseStreamURL(track.URL)
+		if err != nil {
+			continue
+		}
+
+		rewrite(stream)
+
+		u := stream.URL()
+		u.RawQuery = track.URL.RawQuery
+		track.URL = u
+		count++
+	}
+
+	return count
+}
+
+// SetCredentials replaces the credentials of every Xtream Codes stream URL in
+// playlist, and of its xmltv.php guide URLs, on hosts if any are given, as
+// done by RewriteStreams. It returns the number of tracks rewritten.
+func SetCredentials(playlist *m3u.Playlist, credentials Credentials, hosts ...string) int {
+	for _, guide := range []**url.URL{&playlist.TVGURL, &playlist.XTVGURL} {
+		if *guide == nil || path.Base((*guide).Path) != "xmltv.php" || !onHosts(*guide, hosts) {
+			continue
+		}
+
+		query := (*guide).Query()
+		if query.Has("username") {
+			query.Set("username", credentials.Username)
+			query.Set("password", credentials.Password)
+
+			// Copy the URL as it may be shared with other playlists
+			u := **guide
+			u.RawQuery = query.Encode()
+			*guide = &u
+		}
+	}
+
+	return RewriteStreams(playlist, func(s *StreamURL) {
+		s.Credentials = credentials
+	}, hosts...)
+}
+
+// SetOutput changes the container format of every Xtream Codes live stream
+// URL in playlist, on hosts if any are given, as done by RewriteStreams. It
+// returns the number of tracks rewritten.
+func SetOutput(playlist *m3u.Playlist, output Output, hosts ...string) int {
+	return RewriteStreams(playlist, func(s *StreamURL) {
+		s.SetOutput(output)
+	}, hosts...)
+}
+
+// onHosts reports whether u is on one of hosts, with or without its port, or
+// whether hosts is empty.
+func onHosts(u *url.URL, hosts []string) bool {
+	if len(hosts) == 0 {
+		return true
+	}
+
+	for _, host := range hosts {
+		if strings.EqualFold(u.Host, host) || strings.EqualFold(u.Hostname(), host) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isStreamKind(s string) bool {
+	switch StreamKind(s) {
+	case Live, Movie, Series:
+		return true
+	default:
+		return false
+	}
+}
+
+// redactURL returns u without its query string and user information, so that
+// credentials do not leak into error messages.
+func redactURL(u *url.URL) string {
+	redacted := *u
+	redacted.User = nil
+	redacted.RawQuery = ""
+
+	return redacted.String()
+}
@@ -0,0 +1,162 @@
+package xtream_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sherif-fanous/m3u"
+	"github.com/sherif-fanous/m3u/xtream"
+)
+
+func TestParsePlaylistURL(t *testing.T) {
+	t.Parallel()
+
+	p, err := xtream.ParsePlaylistURL("http://127.0.0.1:8080/get.php?username=user&password=pass&type=m3u_plus&output=ts&key=value")
+	if err != nil {
+		t.Fatalf("Failed to parse playlist URL: %v", err)
+	}
+
+	if p.Base.String() != "http://127.0.0.1:8080/" {
+		t.Errorf("Expected base http://127.0.0.1:8080/, got %s", p.Base)
+	}
+
+	expectedCredentials := xtream.Credentials{Username: "user", Password: "pass"}
+	if diff := cmp.Diff(p.Credentials, expectedCredentials); diff != "" {
+		t.Error(diff)
+	}
+
+	if p.Type != "m3u_plus" || p.Output != xtream.OutputTS {
+		t.Errorf("Expected type m3u_plus and output ts, got %s and %s", p.Type, p.Output)
+	}
+
+	p.Password = "secret"
+	p.Output = xtream.OutputHLS
+
+	expected := "http://127.0.0.1:8080/get.php?key=value&output=m3u8&password=secret&type=m3u_plus&username=user"
+	if got := p.String(); got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+
+	_, err = xtream.ParsePlaylistURL("http://127.0.0.1:8080/playlist.m3u?username=user&password=pass")
+	if !errors.Is(err, xtream.ErrNotXtreamURL) {
+		t.Errorf("Expected ErrNotXtreamURL, got: %v", err)
+	}
+}
+
+func TestParseStreamURL(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input    string
+		expected xtream.StreamURL
+	}{
+		{
+			input: "http://127.0.0.1:8080/live/user/pass/123.ts",
+			expected: xtream.StreamURL{
+				Base:        makeURL(t, "http://127.0.0.1:8080/"),
+				Credentials: xtream.Credentials{Username: "user", Password: "pass"},
+				Kind:        xtream.Live,
+				StreamID:    "123",
+				Extension:   "ts",
+			},
+		},
+		{
+			input: "http://127.0.0.1:8080/panel/movie/user/pass/456.mkv",
+			expected: xtream.StreamURL{
+				Base:        makeURL(t, "http://127.0.0.1:8080/panel"),
+				Credentials: xtream.Credentials{Username: "user", Password: "pass"},
+				Kind:        xtream.Movie,
+				StreamID:    "456",
+				Extension:   "mkv",
+			},
+		},
+		{
+			input: "http://127.0.0.1:8080/user/pass/789",
+			expected: xtream.StreamURL{
+				Base:        makeURL(t, "http://127.0.0.1:8080/"),
+				Credentials: xtream.Credentials{Username: "user", Password: "pass"},
+				StreamID:    "789",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			stream, err := xtream.ParseStreamURL(makeURL(t, test.input))
+			if err != nil {
+				t.Fatalf("Failed to parse stream URL: %v", err)
+			}
+
+			if diff := cmp.Diff(*stream, test.expected); diff != "" {
+				t.Error(diff)
+			}
+
+			if got := stream.String(); got != test.input {
+				t.Errorf("Expected rebuilt URL %s, got %s", test.input, got)
+			}
+		})
+	}
+
+	for _, input := range []string{
+		"http://127.0.0.1/stream_1",
+		"http://127.0.0.1/a/b/stream.ts",
+		"http://cdn.example.com/hls/channel/1.m3u8",
+		"http://127.0.0.1/panel/user/pass/1",
+		"file:///live/user/pass/1.ts",
+		"Music/2020/01/3.mp3",
+	} {
+		if _, err := xtream.ParseStreamURL(makeURL(t, input)); !errors.Is(err, xtream.ErrNotXtreamURL) {
+			t.Errorf("%s: expected ErrNotXtreamURL, got: %v", input, err)
+		}
+	}
+}
+
+func TestSetCredentialsAndOutput(t *testing.T) {
+	t.Parallel()
+
+	playlist := &m3u.Playlist{
+		TVGURL: makeURL(t, "http://127.0.0.1/xmltv.php?username=user&password=pass"),
+		Tracks: []m3u.Track{
+			{Length: -1, Name: "Channel 1", URL: makeURL(t, "http://127.0.0.1/live/user/pass/1.ts")},
+			{Length: -1, Name: "Channel 2", URL: makeURL(t, "http://127.0.0.1/user/pass/2")},
+			{Length: -1, Name: "Movie 1", URL: makeURL(t, "http://127.0.0.1/movie/user/pass/3.mp4")},
+			{Length: -1, Name: "Other", URL: makeURL(t, "http://127.0.0.1/stream_4")},
+			{Length: -1, Name: "CDN", URL: makeURL(t, "http://cdn.example.com/hls/channel/1.m3u8")},
+			{Length: 215, Name: "Song", URL: makeURL(t, "Music/2020/01/3.mp3")},
+			{Length: -1, Name: "Other panel", URL: makeURL(t, "http://127.0.0.2/live/user/pass/5.ts")},
+		},
+	}
+
+	credentials := xtream.Credentials{Username: "new", Password: "secret"}
+	if n := xtream.SetCredentials(playlist, credentials, "127.0.0.1"); n != 3 {
+		t.Errorf("Expected 3 rewritten tracks, got %d", n)
+	}
+
+	if n := xtream.SetOutput(playlist, xtream.OutputHLS, "127.0.0.1"); n != 3 {
+		t.Errorf("Expected 3 rewritten tracks, got %d", n)
+	}
+
+	var urls []string
+	for _, track := range playlist.Tracks {
+		urls = append(urls, track.URL.String())
+	}
+
+	expectedURLs := []string{
+		"http://127.0.0.1/live/new/secret/1.m3u8",
+		"http://127.0.0.1/live/new/secret/2.m3u8",
+		"http://127.0.0.1/movie/new/secret/3.mp4",
+		"http://127.0.0.1/stream_4",
+		"http://cdn.example.com/hls/channel/1.m3u8",
+		"Music/2020/01/3.mp3",
+		"http://127.0.0.2/live/user/pass/5.ts",
+	}
+
+	if diff := cmp.Diff(urls, expectedURLs); diff != "" {
+		t.Error(diff)
+	}
+
+	if got := playlist.TVGURL.String(); got != "http://127.0.0.1/xmltv.php?password=secret&username=new" {
+		t.Errorf("Expected rewritten guide URL, got %s", got)
+	}
+}
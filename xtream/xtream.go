@@ -0,0 +1,214 @@
+// Package xtream talks to Xtream Codes IPTV panels and converts their
+// catalogues to and from M3U playlists.
+package xtream
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+)
+
+// StreamKind identifies the kind of content an Xtream Codes stream carries.
+type StreamKind string
+
+const (
+	// Live streams are live TV channels.
+	Live StreamKind = "live"
+	// Movie streams are video on demand.
+	Movie StreamKind = "movie"
+	// Series streams are episodes of a series.
+	Series StreamKind = "series"
+)
+
+// Output is the container format of live streams.
+type Output string
+
+const (
+	// OutputTS streams live channels as MPEG transport streams.
+	OutputTS Output = "ts"
+	// OutputHLS streams live channels as HLS playlists.
+	OutputHLS Output = "m3u8"
+)
+
+// FlexString is a string that also decodes from JSON numbers, booleans and
+// null. Xtream Codes panels are inconsistent about the JSON types of their
+// fields, so identifiers and counters use this type.
+type FlexString string
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *FlexString) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+
+	switch {
+	case bytes.Equal(data, []byte("null")):
+		*s = ""
+	case len(data) > 0 && data[0] == '"':
+		var str string
+		if err := json.Unmarshal(data, &str); err != nil {
+			return err
+		}
+
+		*s = FlexString(str)
+	default:
+		*s = FlexString(data)
+	}
+
+	return nil
+}
+
+// Int returns the value parsed as an integer, or 0 if it is not one.
+func (s FlexString) Int() int {
+	i, _ := strconv.Atoi(string(s))
+	return i
+}
+
+// UserInfo describes the account of the authenticated user.
+type UserInfo struct {
+	Username             string     `json:"username"`
+	Password             string     `json:"password"`
+	Message              string     `json:"message"`
+	Auth                 FlexString `json:"auth"`
+	Status               string     `json:"status"`
+	ExpDate              FlexString `json:"exp_date"`
+	IsTrial              FlexString `json:"is_trial"`
+	ActiveCons           FlexString `json:"active_cons"`
+	CreatedAt            FlexString `json:"created_at"`
+	MaxConnections       FlexString `json:"max_connections"`
+	AllowedOutputFormats []string   `json:"allowed_output_formats"`
+}
+
+// ServerInfo describes the panel serving the account.
+type ServerInfo struct {
+	URL            string     `json:"url"`
+	Port           FlexString `json:"port"`
+	HTTPSPort      FlexString `json:"https_port"`
+	ServerProtocol string     `json:"server_protocol"`
+	RTMPPort       FlexString `json:"rtmp_port"`
+	Timezone       string     `json:"timezone"`
+	TimestampNow   int64      `json:"timestamp_now"`
+	TimeNow        string     `json:"time_now"`
+}
+
+// Account is the response of an authentication request.
+type Account struct {
+	UserInfo   UserInfo   `json:"user_info"`
+	ServerInfo ServerInfo `json:"server_info"`
+}
+
+// Category groups streams of the same kind.
+type Category struct {
+	CategoryID   FlexString `json:"category_id"`
+	CategoryName string     `json:"category_name"`
+	ParentID     FlexString `json:"parent_id"`
+}
+
+// LiveStream is a live TV channel.
+type LiveStream struct {
+	Num               FlexString `json:"num"`
+	Name              string     `json:"name"`
+	StreamType        string     `json:"stream_type"`
+	StreamID          FlexString `json:"stream_id"`
+	StreamIcon        string     `json:"stream_icon"`
+	EPGChannelID      string     `json:"epg_channel_id"`
+	Added             FlexString `json:"added"`
+	CategoryID        FlexString `json:"category_id"`
+	CustomSID         string     `json:"custom_sid"`
+	TVArchive         FlexString `json:"tv_archive"`
+	DirectSource      string     `json:"direct_source"`
+	TVArchiveDuration FlexString `json:"tv_archive_duration"`
+}
+
+// VODStream is a movie.
+type VODStream struct {
+	Num                FlexString `json:"num"`
+	Name               string     `json:"name"`
+	StreamType         string     `json:"stream_type"`
+	StreamID           FlexString `json:"stream_id"`
+	StreamIcon         string     `json:"stream_icon"`
+	Rating             FlexString `json:"rating"`
+	Added              FlexString `json:"added"`
+	CategoryID         FlexString `json:"category_id"`
+	ContainerExtension string     `json:"container_extension"`
+	CustomSID          string     `json:"custom_sid"`
+	DirectSource       string     `json:"direct_source"`
+}
+
+// SeriesStream is a series. Its episodes are listed by SeriesInfo.
+type SeriesStream struct {
+	Num          FlexString `json:"num"`
+	Name         string     `json:"name"`
+	SeriesID     FlexString `json:"series_id"`
+	Cover        string     `json:"cover"`
+	Plot         string     `json:"plot"`
+	Cast         string     `json:"cast"`
+	Director     string     `json:"director"`
+	Genre        string     `json:"genre"`
+	ReleaseDate  string     `json:"releaseDate"`
+	LastModified FlexString `json:"last_modified"`
+	Rating       FlexString `json:"rating"`
+	CategoryID   FlexString `json:"category_id"`
+}
+
+// Episode is an episode of a series.
+type Episode struct {
+	ID                 FlexString  `json:"id"`
+	EpisodeNum         FlexString  `json:"episode_num"`
+	Title              string      `json:"title"`
+	ContainerExtension string      `json:"container_extension"`
+	Season             FlexString  `json:"season"`
+	Info               EpisodeInfo `json:"info"`
+}
+
+// EpisodeInfo holds the metadata of an episode.
+type EpisodeInfo struct {
+	MovieImage   string     `json:"movie_image"`
+	Plot         string     `json:"plot"`
+	DurationSecs FlexString `json:"duration_secs"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Panels send an empty array
+// instead of an object when an episode has no metadata.
+func (i *EpisodeInfo) UnmarshalJSON(data []byte) error {
+	if isEmptyArray(data) {
+		*i = EpisodeInfo{}
+		return nil
+	}
+
+	type episodeInfo EpisodeInfo
+
+	return json.Unmarshal(data, (*episodeInfo)(i))
+}
+
+// SeriesInfo lists the episodes of a series, keyed by season number.
+type SeriesInfo struct {
+	Episodes map[string][]Episode `json:"episodes"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Panels send an empty array
+// instead of an object when a series has no episodes.
+func (i *SeriesInfo) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Episodes json.RawMessage `json:"episodes"`
+	}
+
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*i = SeriesInfo{}
+	if len(raw.Episodes) == 0 || isEmptyArray(raw.Episodes) || bytes.Equal(raw.Episodes, []byte("null")) {
+		return nil
+	}
+
+	return json.Unmarshal(raw.Episodes, &i.Episodes)
+}
+
+// isEmptyArray reports whether data is an empty JSON array.
+func isEmptyArray(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	if len(data) < 2 || data[0] != '[' || data[len(data)-1] != ']' {
+		return false
+	}
+
+	return len(bytes.TrimSpace(data[1:len(data)-1])) == 0
+}